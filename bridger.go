@@ -2,25 +2,41 @@ package go_cache
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/golang/protobuf/proto"
 	"github.com/liyanbing/go-cache/cacher/lru"
 	"github.com/liyanbing/go-cache/cacher/memory"
+	"github.com/liyanbing/go-cache/cacher/tiered"
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/liyanbing/go-cache/invalidation"
+	"github.com/liyanbing/go-cache/logger"
 
 	redisCache "github.com/liyanbing/go-cache/cacher/redis"
 )
 
 type Bridge interface {
 	Cache
+	Namespacer
+	MultiSetter
 	FetchWithJson(ctx context.Context, key string, fetcher Fetcher, model interface{}) (interface{}, error)
 	FetchWithString(ctx context.Context, key string, fetcher Fetcher) (string, error)
 	FetchWithProtobuf(ctx context.Context, key string, fetcher Fetcher, model interface{}) (proto.Message, error)
 	FetchWithNumber(ctx context.Context, key string, fetcher Fetcher) (float64, error)
 	FetchWithArray(ctx context.Context, key string, fetcher Fetcher, model interface{}) (interface{}, error)
+	FetchWithJsonContext(ctx context.Context, key string, fetcher ContextFetcher, model interface{}) (interface{}, error)
+	FetchWithStringContext(ctx context.Context, key string, fetcher ContextFetcher) (string, error)
+	FetchWithProtobufContext(ctx context.Context, key string, fetcher ContextFetcher, model interface{}) (proto.Message, error)
+	FetchWithNumberContext(ctx context.Context, key string, fetcher ContextFetcher) (float64, error)
+	FetchWithArrayContext(ctx context.Context, key string, fetcher ContextFetcher, model interface{}) (interface{}, error)
+	FetchWithCodec(ctx context.Context, key string, fetcher Fetcher, codec Codec, model interface{}) (interface{}, error)
+	FetchWithCodecContext(ctx context.Context, key string, fetcher ContextFetcher, codec Codec, model interface{}) (interface{}, error)
 	FetchWithIncludeKeys(ctx context.Context, output CacheValueOutput, empty EmptyCache, dec Decoder, otherKeys ...string) error
 	FetchWithKeys(ctx context.Context, keys ...string) ([]interface{}, error)
+	InvalidateTags(ctx context.Context, tags ...string) error
+	IncrementNamespaceVersion(ctx context.Context) (int64, error)
 }
 
 type cacheType int8
@@ -30,6 +46,7 @@ const (
 	cacheTypeMemory
 	cacheTypeLRU
 	cacheTypeCustom
+	cacheTypeTiered
 )
 
 type option struct {
@@ -38,6 +55,15 @@ type option struct {
 	cache            Cache
 	memoryMaxEntries int32
 	lruMaxEntries    int
+	group            Group
+	fetchConfig      fetchConfig
+	middlewares      []Middleware
+	l1               Cache
+	l2               Cache
+	l1TTL            time.Duration
+	bus              *invalidation.Bus
+	versioned        bool
+	versionRefresh   time.Duration
 }
 
 func defaultOption() option {
@@ -47,36 +73,177 @@ func defaultOption() option {
 	}
 }
 
-type BridgeOption func(option)
+type BridgeOption func(*option)
 
 func WithRedis(cli *redis.Client) BridgeOption {
-	return func(o option) {
+	return func(o *option) {
 		o.cacheType = cacheTypeRedis
 		o.redisCli = cli
 	}
 }
 
 func WithMemory(maxEntries int32) BridgeOption {
-	return func(o option) {
+	return func(o *option) {
 		o.cacheType = cacheTypeMemory
 		o.memoryMaxEntries = maxEntries
 	}
 }
 
 func WithLRU(maxEntries int32) BridgeOption {
-	return func(o option) {
+	return func(o *option) {
 		o.cacheType = cacheTypeLRU
-
+		o.lruMaxEntries = int(maxEntries)
 	}
 }
 
 func WithCache(cache Cache) BridgeOption {
-	return func(o option) {
+	return func(o *option) {
 		o.cacheType = cacheTypeCustom
 		o.cache = cache
 	}
 }
 
+// WithTiered 两级缓存：先读l1(lru、memory)再读l2(redis)，l2中的数据写入l1时过期时间不超过l1TTL；写入和删除时l1和l2都会写入和删除
+func WithTiered(l1, l2 Cache, l1TTL time.Duration) BridgeOption {
+	return func(o *option) {
+		o.cacheType = cacheTypeTiered
+		o.l1 = l1
+		o.l2 = l2
+		o.l1TTL = l1TTL
+	}
+}
+
+// WithInvalidation Set、MSet、Remove成功之后通过bus通知其他进程删除进程内缓存(lru、memory或者两级缓存的l1)中的数据，
+// 同时从bus中接收其他进程的通知，bus需要调用Start之后才能接收通知
+func WithInvalidation(bus *invalidation.Bus) BridgeOption {
+	return func(o *option) {
+		o.bus = bus
+	}
+}
+
+// WithNamespaceVersion 开启namespace的版本号(需要Cache实现NamespaceVersioner，例如redis、lru、memory)，
+// 版本号会加到每个key的前面，通过Bridge.IncrementNamespaceVersion让所有旧的key失效；
+// 版本号在进程内缓存refreshInterval，小于等于0时为1秒
+func WithNamespaceVersion(refreshInterval time.Duration) BridgeOption {
+	return func(o *option) {
+		o.versioned = true
+		o.versionRefresh = refreshInterval
+	}
+}
+
+// WithGroup 替换Bridge合并并发请求使用的Group
+func WithGroup(g Group) BridgeOption {
+	return func(o *option) {
+		o.group = g
+	}
+}
+
+// WithStaleWhileRevalidate 数据过期(fetcher返回的过期时间)之后的staleTTL时间内仍然返回旧数据，同时在后台刷新缓存
+func WithStaleWhileRevalidate(staleTTL time.Duration) BridgeOption {
+	return func(o *option) {
+		o.fetchConfig.staleWhileRevalidate = staleTTL
+	}
+}
+
+// WithStaleIfError 数据过期之后在staleTTL时间内仍然保留在缓存中，刷新失败时返回旧数据和包装了刷新错误的errors.ErrStale
+func WithStaleIfError(staleTTL time.Duration) BridgeOption {
+	return func(o *option) {
+		o.fetchConfig.staleIfError = staleTTL
+	}
+}
+
+// WithEarlyExpiration 开启XFetch提前刷新：在过期之前根据fetcher花费的时间随机地提前刷新缓存，避免多个进程在同一时刻缓存失效；
+// beta越大越倾向于提前刷新，一般设置为1
+func WithEarlyExpiration(beta float64) BridgeOption {
+	return func(o *option) {
+		o.fetchConfig.earlyExpiration = beta
+	}
+}
+
+// WithFillLock 开启跨进程的填充锁(需要Cache实现Locker，例如redis)：缓存不存在时只有拿到锁的进程调用fetcher，
+// 其他进程每隔pollInterval检查一次缓存，超过expiration还没有等到数据时自己调用fetcher
+func WithFillLock(expiration, pollInterval time.Duration) BridgeOption {
+	return func(o *option) {
+		if pollInterval <= 0 {
+			pollInterval = expiration / 10
+		}
+		if pollInterval <= 0 {
+			pollInterval = time.Millisecond
+		}
+		o.fetchConfig.fillLock = &fillLock{
+			expiration:   expiration,
+			pollInterval: pollInterval,
+		}
+	}
+}
+
+// WithFallback 缓存后端读写失败时调用onError(可以为nil)，读缓存失败时直接调用fetcher返回数据；
+// maxConcurrency限制同时降级调用fetcher的数量，超过时返回缓存后端的错误，小于等于0表示不限制
+func WithFallback(onError BackendErrorHandler, maxConcurrency int) BridgeOption {
+	return func(o *option) {
+		o.fetchConfig.fallback = newFallback(onError, maxConcurrency)
+	}
+}
+
+// WithCompression 编码之后的数据不小于minSize(字节)时通过c压缩之后再写入缓存，例如Gzip(gzip.BestSpeed)；
+// 读取时会自动识别压缩过的数据，没有压缩的旧数据仍然可以正常读取
+func WithCompression(c Compressor, minSize int) BridgeOption {
+	return func(o *option) {
+		RegisterCompressor(c)
+		o.fetchConfig.compression = &compression{
+			compressor: c,
+			minSize:    minSize,
+		}
+	}
+}
+
+// WithEncryption 编码(和压缩)之后的数据通过e加密之后再写入缓存，e为nil时不加密；
+// 读取时根据数据中的key ID选择key解密，没有加密的数据返回errors.ErrDecode，需要读取开启加密之前写入的明文时使用AllowPlaintext()
+func WithEncryption(e *Encryptor, opts ...EncryptionOption) BridgeOption {
+	return func(o *option) {
+		if e == nil {
+			o.fetchConfig.encryption = nil
+			return
+		}
+		enc := &encryption{encryptor: e}
+		for _, opt := range opts {
+			opt(enc)
+		}
+		o.fetchConfig.encryption = enc
+	}
+}
+
+// WithEnvelope fetch写入的数据总是附带元数据(codec、写入时间、过期时间和类型)，可以通过ParseEnvelope查看；
+// 没有元数据的旧数据仍然可以正常读取
+func WithEnvelope() BridgeOption {
+	return func(o *option) {
+		o.fetchConfig.envelope = true
+	}
+}
+
+// WithMiddleware 用中间件包装缓存后端，第一个中间件在最外层；多次调用时之前设置的中间件在外层
+func WithMiddleware(mws ...Middleware) BridgeOption {
+	return func(o *option) {
+		o.middlewares = append(o.middlewares, mws...)
+	}
+}
+
+// WithLogger 设置Bridge和Cache(实现了SetLogger时)使用的日志，默认通过标准库的log输出
+func WithLogger(l logger.Logger) BridgeOption {
+	return func(o *option) {
+		o.fetchConfig.logger = l
+	}
+}
+
+// WithObserver 设置观察缓存事件的Observer，多次调用时会依次通知每个Observer
+func WithObserver(ob Observer) BridgeOption {
+	return func(o *option) {
+		if ob != nil {
+			o.fetchConfig.observer = appendObserver(o.fetchConfig.observer, ob)
+		}
+	}
+}
+
 var (
 	_ Bridge = (*bridger)(nil)
 )
@@ -84,19 +251,23 @@ var (
 type bridger struct {
 	cacheType cacheType
 	Cache
-	redisCli redis.Client
+	redisCli  redis.Client
+	config    fetchConfig
+	bus       *invalidation.Bus
+	versioned *versionedCache
 }
 
-func NewBridge(opts ...BridgeOption) Bridge {
+// NewBridge 根据opts创建Bridge，opts不正确时返回errors.ErrInvalidOption
+func NewBridge(opts ...BridgeOption) (Bridge, error) {
 	o := defaultOption()
 	for _, opt := range opts {
-		opt(o)
+		opt(&o)
 	}
 
 	switch o.cacheType {
 	case cacheTypeRedis:
 		if o.redisCli == nil {
-			log.Fatal("empty redis client")
+			return nil, fmt.Errorf("%w: empty redis client", errors.ErrInvalidOption)
 		}
 		o.cache = redisCache.NewRedisCache(o.redisCli)
 	case cacheTypeMemory:
@@ -111,39 +282,213 @@ func NewBridge(opts ...BridgeOption) Bridge {
 		o.cache = lru.NewLRU(o.lruMaxEntries)
 	case cacheTypeCustom:
 		if o.cache == nil {
-			log.Fatal("empty cache")
+			return nil, fmt.Errorf("%w: empty cache", errors.ErrInvalidOption)
+		}
+	case cacheTypeTiered:
+		if o.l1 == nil || o.l2 == nil {
+			return nil, fmt.Errorf("%w: empty tiered cache", errors.ErrInvalidOption)
+		}
+		o.cache = tiered.NewTieredCache(o.l1, o.l2, o.l1TTL)
+	}
+
+	if o.fetchConfig.logger != nil {
+		if l, ok := findCache[interface{ SetLogger(logger.Logger) }](o.cache); ok {
+			l.SetLogger(o.fetchConfig.logger)
 		}
+	} else {
+		o.fetchConfig.logger = logger.Default()
 	}
+	local, hasLocal := localCache(o.cache)
 
+	var versioned *versionedCache
+	if o.versioned {
+		var err error
+		versioned, err = newVersionedCache(o.cache, o.versionRefresh, o.fetchConfig.logger)
+		if err != nil {
+			return nil, err
+		}
+		o.cache = versioned
+	}
+	if o.bus != nil && hasLocal {
+		o.bus.Register(local)
+	}
+	if len(o.middlewares) > 0 {
+		o.cache = Chain(o.middlewares...)(o.cache)
+	}
+
+	o.fetchConfig.flight = newFlight(o.group)
+	o.fetchConfig.locker, _ = findCache[Locker](o.cache)
+	if ev, ok := findCache[Evictor](o.cache); ok && o.fetchConfig.observer != nil {
+		cache, observer := o.cache, o.fetchConfig.observer
+		ev.SetEvictHandler(func(key string) {
+			observer.OnEvict(namespaceOf(cache), key)
+		})
+	}
 	return &bridger{
-		Cache: o.cache,
+		Cache:     o.cache,
+		config:    o.fetchConfig,
+		bus:       o.bus,
+		versioned: versioned,
+	}, nil
+}
+
+// 需要通过bus同步删除的进程内缓存，两级缓存时为l1
+func localCache(cache Cache) (invalidation.Local, bool) {
+	if t, ok := cache.(*tiered.Tiered); ok {
+		cache = t.L1()
+	}
+	local, ok := cache.(invalidation.Local)
+	return local, ok
+}
+
+// MustNewBridge 和NewBridge一样，opts不正确时panic
+func MustNewBridge(opts ...BridgeOption) Bridge {
+	bridge, err := NewBridge(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return bridge
+}
+
+// Namespace 返回Cache的namespace，Cache没有实现Namespacer时返回""
+func (c *bridger) Namespace() string {
+	return namespaceOf(c.Cache)
+}
+
+// Unwrap 返回Bridge使用的Cache(包括中间件)
+func (c *bridger) Unwrap() Cache {
+	return c.Cache
+}
+
+func (c *bridger) fetchConfig() fetchConfig {
+	return c.config
+}
+
+func (c *bridger) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if !policyFromContext(ctx).canWrite() {
+		return nil
+	}
+	err := c.Cache.Set(ctx, key, value, expiration)
+	if err != nil {
+		return err
+	}
+	c.publish(ctx, key)
+	return c.tag(ctx, expiration, key)
+}
+
+func (c *bridger) Get(ctx context.Context, key string) (interface{}, error) {
+	if !policyFromContext(ctx).canRead() {
+		return nil, errors.ErrEmptyCache
+	}
+	return c.Cache.Get(ctx, key)
+}
+
+func (c *bridger) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	if !policyFromContext(ctx).canRead() {
+		return make([]interface{}, len(keys)), nil
+	}
+	return c.Cache.MGet(ctx, keys...)
+}
+
+func (c *bridger) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	if !policyFromContext(ctx).canWrite() {
+		return nil
+	}
+	err := mset(ctx, c.Cache, values, expiration)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	c.publish(ctx, keys...)
+	return c.tag(ctx, expiration, keys...)
+}
+
+// Remove 主动删除不受Policy影响，只读的策略下也会删除并通知其他进程
+func (c *bridger) Remove(ctx context.Context, key ...string) error {
+	err := c.Cache.Remove(ctx, key...)
+	if err != nil || c.bus == nil {
+		return err
+	}
+	// 删除时其他进程一定要收到通知，所以返回发布失败的错误
+	return c.publishKeys(ctx, key...)
+}
+
+// 通知其他进程删除进程内缓存中的数据，发布的是中间件(例如KeyPrefix)处理之后缓存后端中实际使用的key
+func (c *bridger) publishKeys(ctx context.Context, keys ...string) error {
+	keys, err := backendKeys(ctx, c.Cache, keys)
+	if err != nil {
+		return err
+	}
+	return c.bus.Publish(ctx, c.Namespace(), keys...)
+}
+
+// 通知其他进程删除进程内缓存中的数据，发布失败时只输出日志
+func (c *bridger) publish(ctx context.Context, keys ...string) {
+	if c.bus == nil {
+		return
+	}
+	err := c.publishKeys(ctx, keys...)
+	if err != nil {
+		c.config.logger.Warn("publish invalidation failed", "namespace", c.Namespace(), "keys", keys, "err", err)
 	}
 }
 
 func (c *bridger) FetchWithJson(ctx context.Context, key string, fetcher Fetcher, model interface{}) (interface{}, error) {
-	return FetchWithJson(ctx, c.Cache, key, fetcher, model)
+	return FetchWithJson(ctx, c, key, fetcher, model)
 }
 
 func (c *bridger) FetchWithString(ctx context.Context, key string, fetcher Fetcher) (string, error) {
-	return FetchWithString(ctx, c.Cache, key, fetcher)
+	return FetchWithString(ctx, c, key, fetcher)
 }
 
 func (c *bridger) FetchWithProtobuf(ctx context.Context, key string, fetcher Fetcher, model interface{}) (proto.Message, error) {
-	return FetchWithProtobuf(ctx, c.Cache, key, fetcher, model)
+	return FetchWithProtobuf(ctx, c, key, fetcher, model)
 }
 
 func (c *bridger) FetchWithNumber(ctx context.Context, key string, fetcher Fetcher) (float64, error) {
-	return FetchWithNumber(ctx, c.Cache, key, fetcher)
+	return FetchWithNumber(ctx, c, key, fetcher)
 }
 
 func (c *bridger) FetchWithArray(ctx context.Context, key string, fetcher Fetcher, model interface{}) (interface{}, error) {
-	return FetchWithArray(ctx, c.Cache, key, fetcher, model)
+	return FetchWithArray(ctx, c, key, fetcher, model)
+}
+
+func (c *bridger) FetchWithJsonContext(ctx context.Context, key string, fetcher ContextFetcher, model interface{}) (interface{}, error) {
+	return FetchWithJsonContext(ctx, c, key, fetcher, model)
+}
+
+func (c *bridger) FetchWithStringContext(ctx context.Context, key string, fetcher ContextFetcher) (string, error) {
+	return FetchWithStringContext(ctx, c, key, fetcher)
+}
+
+func (c *bridger) FetchWithProtobufContext(ctx context.Context, key string, fetcher ContextFetcher, model interface{}) (proto.Message, error) {
+	return FetchWithProtobufContext(ctx, c, key, fetcher, model)
+}
+
+func (c *bridger) FetchWithNumberContext(ctx context.Context, key string, fetcher ContextFetcher) (float64, error) {
+	return FetchWithNumberContext(ctx, c, key, fetcher)
+}
+
+func (c *bridger) FetchWithArrayContext(ctx context.Context, key string, fetcher ContextFetcher, model interface{}) (interface{}, error) {
+	return FetchWithArrayContext(ctx, c, key, fetcher, model)
+}
+
+func (c *bridger) FetchWithCodec(ctx context.Context, key string, fetcher Fetcher, codec Codec, model interface{}) (interface{}, error) {
+	return FetchWithCodec(ctx, c, key, fetcher, codec, model)
+}
+
+func (c *bridger) FetchWithCodecContext(ctx context.Context, key string, fetcher ContextFetcher, codec Codec, model interface{}) (interface{}, error) {
+	return FetchWithCodecContext(ctx, c, key, fetcher, codec, model)
 }
 
 func (c *bridger) FetchWithIncludeKeys(ctx context.Context, output CacheValueOutput, empty EmptyCache, dec Decoder, otherKeys ...string) error {
-	return FetchWithIncludeKeys(ctx, c.Cache, output, empty, dec, otherKeys...)
+	return FetchWithIncludeKeys(ctx, c, output, empty, dec, otherKeys...)
 }
 
 func (c *bridger) FetchWithKeys(ctx context.Context, keys ...string) ([]interface{}, error) {
-	return FetchWithKeys(ctx, c.Cache, keys...)
+	return FetchWithKeys(ctx, c, keys...)
 }
@@ -0,0 +1,184 @@
+package go_cache
+
+import (
+	"bytes"
+	"context"
+	stdErrors "errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/liyanbing/go-cache/invalidation"
+	"github.com/liyanbing/go-cache/logger"
+	"github.com/stretchr/testify/assert"
+
+	redis "github.com/go-redis/redis/v8"
+	redisCache "github.com/liyanbing/go-cache/cacher/redis"
+)
+
+func TestNewBridge(t *testing.T) {
+	ast := assert.New(t)
+
+	_, err := NewBridge(WithRedis(nil))
+	ast.True(stdErrors.Is(err, errors.ErrInvalidOption))
+
+	_, err = NewBridge(WithCache(nil))
+	ast.True(stdErrors.Is(err, errors.ErrInvalidOption))
+
+	_, err = NewBridge(WithTiered(lru.NewLRU(10), nil, time.Second))
+	ast.True(stdErrors.Is(err, errors.ErrInvalidOption))
+
+	ast.Panics(func() {
+		MustNewBridge(WithCache(nil))
+	})
+
+	bridge, err := NewBridge(WithLRU(10))
+	ast.Nil(err)
+	ast.NotNil(bridge)
+
+	// 两级缓存
+	l1, l2 := lru.NewLRU(10), lru.NewLRU(10)
+	bridge, err = NewBridge(WithTiered(l1, l2, time.Second))
+	ast.Nil(err)
+	ret, err := bridge.FetchWithString(context.Background(), "tiered-key", func() (interface{}, time.Duration, error) {
+		return "abc", time.Minute, nil
+	})
+	ast.Nil(err)
+	ast.Equal("abc", ret)
+	_, err = l1.Get(context.Background(), "tiered-key")
+	ast.Nil(err)
+	_, err = l2.Get(context.Background(), "tiered-key")
+	ast.Nil(err)
+}
+
+func TestBridgeLogger(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	bridge := MustNewBridge(WithCache(&writeBrokenCache{brokenCache{LRU: lru.NewLRU(10)}}),
+		WithLogger(logger.Std(log.New(&buf, "", 0), logger.LevelInfo)))
+
+	ret, err := bridge.FetchWithString(ctx, "log-key", func() (interface{}, time.Duration, error) {
+		return "abc", time.Second, nil
+	})
+	ast.Nil(err)
+	ast.Equal("abc", ret)
+	ast.True(strings.HasPrefix(buf.String(), "WARN set cache failed key=log-key err="))
+}
+
+func TestBridgeInvalidation(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	m := miniredis.NewMiniRedis()
+	ast.Nil(m.Start())
+	defer m.Close()
+
+	newTiered := func() (Bridge, *lru.LRU) {
+		cli := redis.NewClient(&redis.Options{Addr: m.Addr()})
+		bus := invalidation.NewBus(cli)
+		ast.Nil(bus.Start(ctx))
+		t.Cleanup(func() { _ = bus.Close() })
+
+		l1 := lru.NewLRU(10)
+		bridge := MustNewBridge(WithTiered(l1, redisCache.NewRedisCache(cli), time.Minute), WithInvalidation(bus))
+		bridge.SetNamespace("inv")
+		return bridge, l1
+	}
+	bridgeA, l1A := newTiered()
+	bridgeB, l1B := newTiered()
+
+	fetchFunc := func() (interface{}, time.Duration, error) {
+		return "v1", time.Minute, nil
+	}
+	ret, err := bridgeB.FetchWithString(ctx, "key", fetchFunc)
+	ast.Nil(err)
+	ast.Equal("v1", ret)
+	_, err = l1B.Get(ctx, "key")
+	ast.Nil(err)
+
+	// A写入之后B进程内的缓存被删除，重新从redis中获取
+	ast.Nil(bridgeA.Set(ctx, "key", "v2", time.Minute))
+	_, err = l1A.Get(ctx, "key")
+	ast.Nil(err)
+	ast.Eventually(func() bool {
+		_, err := l1B.Get(ctx, "key")
+		return err == errors.ErrEmptyCache
+	}, time.Second, time.Millisecond*5)
+
+	ret, err = bridgeB.FetchWithString(ctx, "key", fetchFunc)
+	ast.Nil(err)
+	ast.Equal("v2", ret)
+
+	// 删除
+	ast.Nil(bridgeA.Remove(ctx, "key"))
+	ast.Eventually(func() bool {
+		_, err := l1B.Get(ctx, "key")
+		return err == errors.ErrEmptyCache
+	}, time.Second, time.Millisecond*5)
+}
+
+func TestBridgeInvalidation_KeyPrefix(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	m := miniredis.NewMiniRedis()
+	ast.Nil(m.Start())
+	defer m.Close()
+
+	for i, opts := range [][]BridgeOption{
+		{WithMiddleware(KeyPrefix("p:"))},
+		{WithMiddleware(KeyPrefix("p:")), WithNamespaceVersion(time.Minute)},
+	} {
+		newTiered := func() (Bridge, *lru.LRU) {
+			cli := redis.NewClient(&redis.Options{Addr: m.Addr()})
+			bus := invalidation.NewBus(cli)
+			ast.Nil(bus.Start(ctx))
+			t.Cleanup(func() { _ = bus.Close() })
+
+			l1 := lru.NewLRU(10)
+			bridge := MustNewBridge(append(opts, WithTiered(l1, redisCache.NewRedisCache(cli), time.Minute), WithInvalidation(bus))...)
+			bridge.SetNamespace("inv_prefix")
+			return bridge, l1
+		}
+		bridgeA, _ := newTiered()
+		bridgeB, l1B := newTiered()
+		if i == 1 {
+			_, err := bridgeA.IncrementNamespaceVersion(ctx)
+			ast.Nil(err)
+		}
+
+		// B进程内的缓存中实际的key
+		l1Key := "p:key"
+		if i == 1 {
+			l1Key = "__v1__:p:key"
+		}
+		_, err := bridgeB.FetchWithString(ctx, "key", func() (interface{}, time.Duration, error) {
+			return "v1", time.Minute, nil
+		})
+		ast.Nil(err)
+		_, err = l1B.Get(ctx, l1Key)
+		ast.Nil(err)
+
+		ast.Nil(bridgeA.Set(ctx, "key", "v2", time.Minute))
+		ast.Eventually(func() bool {
+			_, err := l1B.Get(ctx, l1Key)
+			return err == errors.ErrEmptyCache
+		}, time.Second, time.Millisecond*5)
+
+		ret, err := bridgeB.FetchWithString(ctx, "key", nil)
+		ast.Nil(err)
+		ast.Equal("v2", ret)
+
+		ast.Nil(bridgeA.Remove(ctx, "key"))
+		ast.Eventually(func() bool {
+			_, err := l1B.Get(ctx, l1Key)
+			return err == errors.ErrEmptyCache
+		}, time.Second, time.Millisecond*5)
+	}
+}
@@ -2,11 +2,12 @@ package go_cache
 
 import (
 	"context"
+	stdErrors "errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
-	"github.com/golang/groupcache/singleflight"
 	"github.com/golang/protobuf/proto"
 	"github.com/liyanbing/go-cache/errors"
 	"github.com/liyanbing/go-cache/tools"
@@ -23,8 +24,7 @@ import (
  */
 
 var (
-	single = &singleflight.Group{}
-	json   = jsonIter.ConfigCompatibleWithStandardLibrary
+	json = jsonIter.ConfigCompatibleWithStandardLibrary
 )
 
 /**
@@ -34,6 +34,15 @@ var (
  */
 type Fetcher func() (value interface{}, expiration time.Duration, err error)
 
+// ContextFetcher 可以感知ctx的Fetcher，ctx在所有等待的调用方都取消之后才会被取消
+type ContextFetcher func(ctx context.Context) (value interface{}, expiration time.Duration, err error)
+
+func (f Fetcher) withContext() ContextFetcher {
+	return func(context.Context) (interface{}, time.Duration, error) {
+		return f()
+	}
+}
+
 type EmptyCache func(outerKey string)
 
 type CacheValueOutput func(value interface{}) error
@@ -45,17 +54,59 @@ type Cache interface {
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
 	// get value of key , return errors.ErrEmptyCache if not found key from bridger
 	Get(ctx context.Context, key string) (interface{}, error)
+	// get values of keys, the value of key which not found is nil
 	MGet(ctx context.Context, keys ...string) ([]interface{}, error)
 	// remove value by key
 	Remove(ctx context.Context, key ...string) error
 }
 
+// Namespacer 可选接口，Cache实现之后观察者、日志、失效广播等可以拿到当前的namespace
+type Namespacer interface {
+	// get global namespace
+	Namespace() string
+}
+
+// MultiSetter 可选接口，Cache实现之后批量写入(FetchMulti等)时一次写入多个key
+type MultiSetter interface {
+	// set values with the same expiration time
+	MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error
+}
+
+// 批量写入，cache没有实现MultiSetter时依次调用Set
+func mset(ctx context.Context, cache Cache, values map[string]interface{}, expiration time.Duration) error {
+	if m, ok := cache.(MultiSetter); ok {
+		return m.MSet(ctx, values, expiration)
+	}
+	for key, value := range values {
+		if err := cache.Set(ctx, key, value, expiration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 获取cache的namespace，会沿着Unwrap查找，没有实现Namespacer时返回""
+func namespaceOf(cache Cache) string {
+	if n, ok := findCache[Namespacer](cache); ok {
+		return n.Namespace()
+	}
+	return ""
+}
+
 func FetchWithJson(ctx context.Context, cache Cache, key string, fetcher Fetcher, model interface{}) (interface{}, error) {
-	return fetch(ctx, cache, key, fetcher, jsonEncode, JsonDecode(model))
+	return FetchWithJsonContext(ctx, cache, key, fetcher.withContext(), model)
+}
+
+func FetchWithJsonContext(ctx context.Context, cache Cache, key string, fetcher ContextFetcher, model interface{}) (interface{}, error) {
+	return fetch(ctx, cache, key, fetcher, CodecJson, jsonEncode, JsonDecode(model))
 }
 
 func FetchWithString(ctx context.Context, cache Cache, key string, fetcher Fetcher) (string, error) {
-	value, err := fetch(ctx, cache, key, fetcher, func(input interface{}) ([]byte, error) {
+	return FetchWithStringContext(ctx, cache, key, fetcher.withContext())
+}
+
+func FetchWithStringContext(ctx context.Context, cache Cache, key string, fetcher ContextFetcher) (string, error) {
+	value, err := fetch(ctx, cache, key, fetcher, CodecString, func(input interface{}) ([]byte, error) {
 		var data []byte
 		switch input.(type) {
 		case string:
@@ -67,73 +118,140 @@ func FetchWithString(ctx context.Context, cache Cache, key string, fetcher Fetch
 	}, func(value interface{}) (interface{}, error) {
 		return tools.ToString(value)
 	})
-	if err != nil {
+	if err != nil && !isStale(err) {
 		return "", err
 	}
-	return value.(string), nil
+	return value.(string), err
 }
 
 func FetchWithProtobuf(ctx context.Context, cache Cache, key string, fetcher Fetcher, model interface{}) (proto.Message, error) {
-	value, err := fetch(ctx, cache, key, fetcher, protoEncode, ProtoDecode(model))
-	if err != nil {
+	return FetchWithProtobufContext(ctx, cache, key, fetcher.withContext(), model)
+}
+
+func FetchWithProtobufContext(ctx context.Context, cache Cache, key string, fetcher ContextFetcher, model interface{}) (proto.Message, error) {
+	value, err := fetch(ctx, cache, key, fetcher, CodecProtobuf, protoEncode, ProtoDecode(model))
+	if err != nil && !isStale(err) {
 		return nil, err
 	}
-	return value.(proto.Message), nil
+	return value.(proto.Message), err
 }
 
 func FetchWithNumber(ctx context.Context, cache Cache, key string, fetcher Fetcher) (float64, error) {
-	value, err := fetch(ctx, cache, key, fetcher, func(i interface{}) ([]byte, error) {
+	return FetchWithNumberContext(ctx, cache, key, fetcher.withContext())
+}
+
+func FetchWithNumberContext(ctx context.Context, cache Cache, key string, fetcher ContextFetcher) (float64, error) {
+	value, err := fetch(ctx, cache, key, fetcher, CodecNumber, func(i interface{}) ([]byte, error) {
 		if !tools.CanConvertToNumber(i) {
-			return nil, errors.ErrInvalidValue
+			return nil, errors.NewEncodeError("", errors.ErrInvalidValue)
 		}
 		return []byte(fmt.Sprintf("%v", i)), nil
 	}, func(value interface{}) (interface{}, error) {
 		return value, nil
 	})
-	if err != nil {
+	if err != nil && !isStale(err) {
 		return 0, err
 	}
-	return tools.ToFloat(value)
+
+	ret, convErr := tools.ToFloat(value)
+	if convErr != nil {
+		return 0, errors.NewDecodeError(key, convErr)
+	}
+	return ret, err
 }
 
 func FetchWithArray(ctx context.Context, cache Cache, key string, fetcher Fetcher, model interface{}) (interface{}, error) {
-	return fetch(ctx, cache, key, fetcher, func(i interface{}) ([]byte, error) {
+	return FetchWithArrayContext(ctx, cache, key, fetcher.withContext(), model)
+}
+
+func FetchWithArrayContext(ctx context.Context, cache Cache, key string, fetcher ContextFetcher, model interface{}) (interface{}, error) {
+	return fetch(ctx, cache, key, fetcher, CodecJson, func(i interface{}) ([]byte, error) {
 		kind := reflect.TypeOf(i).Kind()
 		if kind != reflect.Slice && kind != reflect.Array {
-			return nil, errors.ErrInvalidValue
+			return nil, errors.NewEncodeError("", errors.ErrInvalidValue)
 		}
 		return jsonEncode(i)
 	}, func(value interface{}) (interface{}, error) {
 		dataValue, ok := value.([]byte)
 		if !ok {
-			return nil, errors.ErrInvalidCacheValue
+			return nil, errors.NewDecodeError("", errors.ErrInvalidCacheValue)
 		}
 
 		ret := reflect.New(reflect.MakeSlice(typeFromModel(model), 0, 0).Type())
 		err := json.Unmarshal(dataValue, ret.Interface())
 		if err != nil {
-			return nil, err
+			return nil, errors.NewDecodeError("", err)
 		}
 		return ret.Elem().Interface(), nil
 	})
 }
 
+// FetchWithCodec 使用codec编解码，codec可以是内置的(LookupCodec)或者自己实现的Codec；
+// 和FetchWithJson一样，从缓存中获取到数据时返回的是codec解码之后的对象
+func FetchWithCodec(ctx context.Context, cache Cache, key string, fetcher Fetcher, codec Codec, model interface{}) (interface{}, error) {
+	return FetchWithCodecContext(ctx, cache, key, fetcher.withContext(), codec, model)
+}
+
+func FetchWithCodecContext(ctx context.Context, cache Cache, key string, fetcher ContextFetcher, codec Codec, model interface{}) (interface{}, error) {
+	return fetch(ctx, cache, key, fetcher, codec.Name(), codecEncoder(codec), codecDecoder(codec, model))
+}
+
 // 批量获取otherKeys的缓存数据，如果缓存中不存在则会通过fetcher获取不存在缓存中的数据，通过fetcher获取到的数据不会加入缓存
+// 每个key只会回调一次：缓存中的数据通过output返回，不存在(包括缓存了"不存在")的key通过empty返回
 func FetchWithIncludeKeys(ctx context.Context, cache Cache, output CacheValueOutput, empty EmptyCache, dec Decoder, otherKeys ...string) error {
+	cfg := newFetchConfig(ctx, cache)
+	if !cfg.policy.canRead() {
+		for _, key := range otherKeys {
+			empty(key)
+		}
+		return nil
+	}
+
+	namespace := namespaceOf(cache)
 	for _, key := range otherKeys {
 		cachedValue, err := cache.Get(ctx, key)
 		if err == errors.ErrEmptyCache {
+			cfg.observer.OnMiss(ctx, namespace, key)
 			empty(key)
 			continue
 		}
 		if err != nil {
-			return err
+			err = errors.NewBackendError("", key, err)
+			cfg.reportBackendError(ctx, key, err)
+			if cfg.fallback == nil {
+				return err
+			}
+			// 降级时当作缓存中不存在
+			empty(key)
+			continue
 		}
 
-		value, err := dec(cachedValue)
+		it := newItem(cachedValue)
+		if it.notFound {
+			// 已经确定不存在的数据，同样通过empty返回
+			cfg.observer.OnHit(ctx, namespace, key)
+			empty(key)
+			continue
+		}
+		if cfg.tooStale(it, time.Now()) {
+			// 过期之后为了stale-while-revalidate、stale-if-error保留的数据
+			cfg.observer.OnMiss(ctx, namespace, key)
+			empty(key)
+			continue
+		}
+
+		value, err := cfg.decode(dec, it)
+		if isSchemaMismatch(err) {
+			cfg.observer.OnMiss(ctx, namespace, key)
+			empty(key)
+			continue
+		}
 		if err != nil {
+			err = errors.NewDecodeError(key, err)
+			cfg.observer.OnDecodeError(ctx, namespace, key, err)
 			return err
 		}
+		cfg.observer.OnHit(ctx, namespace, key)
 
 		err = output(value)
 		if err != nil {
@@ -144,5 +262,41 @@ func FetchWithIncludeKeys(ctx context.Context, cache Cache, output CacheValueOut
 }
 
 func FetchWithKeys(ctx context.Context, cache Cache, keys ...string) ([]interface{}, error) {
-	return cache.MGet(ctx, keys...)
+	cfg := newFetchConfig(ctx, cache)
+	if !cfg.policy.canRead() {
+		return make([]interface{}, len(keys)), nil
+	}
+
+	values, err := cache.MGet(ctx, keys...)
+	if err != nil {
+		err = errors.NewBackendError("", strings.Join(keys, ","), err)
+		cfg.reportBackendError(ctx, strings.Join(keys, ","), err)
+		if cfg.fallback == nil {
+			return nil, err
+		}
+		// 降级时当作缓存中都不存在
+		return make([]interface{}, len(keys)), nil
+	}
+
+	now := time.Now()
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		it := newItem(value)
+		if it.notFound || cfg.tooStale(it, now) {
+			values[i] = nil
+			continue
+		}
+		values[i], err = cfg.decodePayload(it)
+		if err != nil {
+			return nil, errors.NewDecodeError(keys[i], err)
+		}
+	}
+	return values, nil
+}
+
+// 刷新失败时返回了旧数据(stale-if-error)，这时候需要同时返回数据和错误
+func isStale(err error) bool {
+	return stdErrors.Is(err, errors.ErrStale)
 }
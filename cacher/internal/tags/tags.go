@@ -0,0 +1,88 @@
+package tags
+
+import "sync"
+
+// Index 进程内缓存(lru、memory)的tag到key的索引，key不带namespace
+type Index struct {
+	mu   sync.Mutex
+	keys map[string]map[string]struct{} // tag -> keys
+	tags map[string]map[string]struct{} // key -> tags，删除key时清理索引
+}
+
+func (i *Index) Add(tags []string, keys ...string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.keys == nil {
+		i.keys = make(map[string]map[string]struct{})
+		i.tags = make(map[string]map[string]struct{})
+	}
+	for _, tag := range tags {
+		for _, key := range keys {
+			add(i.keys, tag, key)
+			add(i.tags, key, tag)
+		}
+	}
+}
+
+// Take 返回tags下所有的key，同时删除这些tag
+func (i *Index) Take(tags ...string) []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	seen := make(map[string]struct{})
+	ret := make([]string, 0)
+	for _, tag := range tags {
+		for key := range i.keys[tag] {
+			remove(i.tags, key, tag)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			ret = append(ret, key)
+		}
+		delete(i.keys, tag)
+	}
+	return ret
+}
+
+// Delete key被删除或者淘汰之后从索引中删除
+func (i *Index) Delete(keys ...string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, key := range keys {
+		for tag := range i.tags[key] {
+			remove(i.keys, tag, key)
+		}
+		delete(i.tags, key)
+	}
+}
+
+// Reset 清空索引
+func (i *Index) Reset() {
+	i.mu.Lock()
+	i.keys = nil
+	i.tags = nil
+	i.mu.Unlock()
+}
+
+func add(m map[string]map[string]struct{}, name, value string) {
+	set, ok := m[name]
+	if !ok {
+		set = make(map[string]struct{})
+		m[name] = set
+	}
+	set[value] = struct{}{}
+}
+
+func remove(m map[string]map[string]struct{}, name, value string) {
+	set, ok := m[name]
+	if !ok {
+		return
+	}
+	delete(set, value)
+	if len(set) == 0 {
+		delete(m, name)
+	}
+}
@@ -3,27 +3,69 @@ package lru
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/groupcache/lru"
+	"github.com/liyanbing/go-cache/cacher/internal/tags"
 	"github.com/liyanbing/go-cache/errors"
 )
 
+type entry struct {
+	value  interface{}
+	expire int64 // 过期时间(unix nano)，0表示不过期
+}
+
 type LRU struct {
+	mu        sync.Mutex // groupcache的lru不是并发安全的
 	cache     *lru.Cache
 	namespace string
+	onEvict   func(key string)
+	removing  bool // 调用Remove删除的数据不是淘汰
+	tags      tags.Index
+	version   int64 // namespace的版本号，只在进程内有效
 }
 
 func NewLRU(max int) *LRU {
-	return &LRU{
+	s := &LRU{
 		cache: lru.New(max),
 	}
+	s.cache.OnEvicted = s.evicted
+	return s
+}
+
+// SetEvictHandler 数据因为容量不足或者过期被淘汰时调用fn，key不带namespace；fn中不能再调用LRU的方法
+func (s *LRU) SetEvictHandler(fn func(key string)) {
+	s.mu.Lock()
+	s.onEvict = fn
+	s.mu.Unlock()
+}
+
+// 调用时已经持有锁
+func (s *LRU) evicted(key lru.Key, _ interface{}) {
+	if s.removing {
+		return
+	}
+
+	name := key.(string)
+	if s.namespace != "" {
+		name = strings.TrimPrefix(name, s.namespace+":")
+	}
+	s.tags.Delete(name)
+	if s.onEvict != nil {
+		s.onEvict(name)
+	}
 }
 
 func (s *LRU) SetNamespace(namespace string) {
 	s.namespace = namespace
 }
 
+func (s *LRU) Namespace() string {
+	return s.namespace
+}
+
 func (s *LRU) namespaceKey(key string) string {
 	if s.namespace == "" {
 		return key
@@ -31,15 +73,26 @@ func (s *LRU) namespaceKey(key string) string {
 	return fmt.Sprintf("%v:%v", s.namespace, key)
 }
 
+// Set expiration大于0时数据到期后会被删除，小于等于0时数据不会过期，和redis一样
+// 注意：之前的版本中lru会忽略expiration，数据只会被lru淘汰，升级后设置了expiration的数据到期就会消失
 func (s *LRU) Set(_ context.Context, key string, value interface{}, expiration time.Duration) error {
 	key = s.namespaceKey(key)
-	s.cache.Add(lru.Key(key), value)
+	data := &entry{
+		value: value,
+	}
+	if expiration > 0 {
+		data.expire = time.Now().Add(expiration).UnixNano()
+	}
+
+	s.mu.Lock()
+	s.cache.Add(lru.Key(key), data)
+	s.mu.Unlock()
 	return nil
 }
 
 func (s *LRU) Get(_ context.Context, key string) (interface{}, error) {
 	key = s.namespaceKey(key)
-	value, ok := s.cache.Get(lru.Key(key))
+	value, ok := s.get(key)
 	if !ok {
 		return nil, errors.ErrEmptyCache
 	}
@@ -49,17 +102,88 @@ func (s *LRU) Get(_ context.Context, key string) (interface{}, error) {
 func (s *LRU) MGet(_ context.Context, keys ...string) ([]interface{}, error) {
 	values := make([]interface{}, 0, len(keys))
 	for _, key := range keys {
-		value, ok := s.cache.Get(lru.Key(key))
-		if ok {
-			values = append(values, value)
-		}
+		value, _ := s.get(s.namespaceKey(key))
+		values = append(values, value)
 	}
 	return values, nil
 }
 
+func (s *LRU) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	for key, value := range values {
+		err := s.Set(ctx, key, value, expiration)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *LRU) get(key string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.cache.Get(lru.Key(key))
+	if !ok {
+		return nil, false
+	}
+
+	data := value.(*entry)
+	if data.expire > 0 && time.Now().UnixNano() > data.expire {
+		s.cache.Remove(lru.Key(key))
+		return nil, false
+	}
+	return data.value, true
+}
+
+// Flush 删除所有的数据
+func (s *LRU) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removing = true
+	s.cache.Clear()
+	s.removing = false
+	s.tags.Reset()
+}
+
+// NamespaceVersion 返回进程内namespace的版本号
+func (s *LRU) NamespaceVersion(_ context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.version, nil
+}
+
+// IncrNamespaceVersion 增加版本号，旧版本的数据不会再被读到，所以同时清空缓存
+func (s *LRU) IncrNamespaceVersion(_ context.Context) (int64, error) {
+	s.Flush()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	return s.version, nil
+}
+
+// Tag 给keys加上tags，过期时间由key自己决定
+func (s *LRU) Tag(_ context.Context, tags []string, _ time.Duration, keys ...string) error {
+	s.tags.Add(tags, keys...)
+	return nil
+}
+
+// InvalidateTags 删除tags下所有的key，返回删除的key
+func (s *LRU) InvalidateTags(ctx context.Context, tags ...string) ([]string, error) {
+	keys := s.tags.Take(tags...)
+	return keys, s.Remove(ctx, keys...)
+}
+
 func (s *LRU) Remove(_ context.Context, key ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removing = true
 	for _, value := range key {
 		s.cache.Remove(lru.Key(s.namespaceKey(value)))
 	}
+	s.removing = false
+	s.tags.Delete(key...)
 	return nil
 }
@@ -3,6 +3,7 @@ package lru
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/liyanbing/go-cache/errors"
 	"github.com/stretchr/testify/assert"
@@ -69,3 +70,56 @@ func TestLRU_Get(t *testing.T) {
 	value, err = instance.Get(context.Background(), "name1")
 	assert.Equal(t, errors.ErrEmptyCache, err)
 }
+
+func TestLRU_Expiration(t *testing.T) {
+	instance := NewLRU(10)
+
+	err := instance.Set(context.Background(), "name", "value", time.Millisecond*50)
+	assert.Nil(t, err)
+
+	value, err := instance.Get(context.Background(), "name")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", value)
+
+	time.Sleep(time.Millisecond * 60)
+	_, err = instance.Get(context.Background(), "name")
+	assert.Equal(t, errors.ErrEmptyCache, err)
+}
+
+func TestLRU_MGet(t *testing.T) {
+	instance := NewLRU(10)
+	instance.SetNamespace("test")
+
+	err := instance.MSet(context.Background(), map[string]interface{}{
+		"name":  "value",
+		"name1": "value1",
+	}, time.Minute)
+	assert.Nil(t, err)
+
+	values, err := instance.MGet(context.Background(), "name", "name2", "name1")
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"value", nil, "value1"}, values)
+}
+
+func TestLRU_Flush(t *testing.T) {
+	instance := NewLRU(2)
+	instance.SetNamespace("test")
+
+	var evicted []string
+	instance.SetEvictHandler(func(key string) {
+		evicted = append(evicted, key)
+	})
+
+	_ = instance.Set(context.Background(), "name", "value", time.Minute)
+	_ = instance.Set(context.Background(), "name1", "value1", time.Minute)
+	_ = instance.Set(context.Background(), "name2", "value2", time.Minute)
+	assert.Equal(t, []string{"name"}, evicted)
+
+	// 删除和清空不是淘汰
+	_ = instance.Remove(context.Background(), "name1")
+	instance.Flush()
+	assert.Equal(t, []string{"name"}, evicted)
+
+	_, err := instance.Get(context.Background(), "name2")
+	assert.Equal(t, errors.ErrEmptyCache, err)
+}
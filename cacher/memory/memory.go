@@ -3,23 +3,27 @@ package memory
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/liyanbing/go-cache/cacher/internal/tags"
 	"github.com/liyanbing/go-cache/errors"
+	"github.com/liyanbing/go-cache/logger"
 )
 
 func NewMemoryCache(max int32) *Memory {
 	return &Memory{
 		MaxEntries: max,
 		quit:       make(chan struct{}, 1),
+		logger:     logger.Nop(),
 	}
 }
 
 type entry struct {
 	value  interface{}
-	expire int64
+	expire int64 // 过期时间(unix nano)，0表示不过期
 }
 
 type Memory struct {
@@ -28,12 +32,30 @@ type Memory struct {
 	MaxEntries int32
 	entriesNum int32
 	namespace  string
+	onEvict    func(key string)
+	logger     logger.Logger
+	tags       tags.Index
+	version    int64 // namespace的版本号，只在进程内有效
+}
+
+// SetLogger 设置日志，缓存已满写入失败时输出warn日志
+func (m *Memory) SetLogger(l logger.Logger) {
+	m.logger = l
+}
+
+// SetEvictHandler 过期的数据被删除时调用fn，key不带namespace；需要在使用之前设置
+func (m *Memory) SetEvictHandler(fn func(key string)) {
+	m.onEvict = fn
 }
 
 func (m *Memory) SetNamespace(namespace string) {
 	m.namespace = namespace
 }
 
+func (m *Memory) Namespace() string {
+	return m.namespace
+}
+
 func (m *Memory) namespaceKey(key string) string {
 	if m.namespace == "" {
 		return key
@@ -41,18 +63,25 @@ func (m *Memory) namespaceKey(key string) string {
 	return fmt.Sprintf("%v:%v", m.namespace, key)
 }
 
+// Set expiration小于等于0时数据不会过期，和redis一样
+// 注意：之前的版本中expiration为0的数据会立即过期(相当于不写入)
 func (m *Memory) Set(_ context.Context, key string, value interface{}, expiration time.Duration) error {
 	key = m.namespaceKey(key)
 	entriesNum := atomic.LoadInt32(&m.entriesNum)
 	if m.MaxEntries > 0 && m.MaxEntries <= entriesNum {
+		m.logger.Warn("memory cache is full", "max_entries", m.MaxEntries, "key", key)
 		return nil
 	}
 
+	data := &entry{
+		value: value,
+	}
+	if expiration > 0 {
+		data.expire = time.Now().Add(expiration).UnixNano()
+	}
+
 	atomic.AddInt32(&m.entriesNum, 1)
-	m.cache.Store(key, &entry{
-		value:  value,
-		expire: time.Now().Add(expiration).UnixNano(),
-	})
+	m.cache.Store(key, data)
 	return nil
 }
 
@@ -69,37 +98,85 @@ func (m *Memory) Get(_ context.Context, key string) (interface{}, error) {
 	return value.(*entry).value, nil
 }
 
-func (m *Memory) MGet(_ context.Context, keys ...string) ([]interface{}, error) {
+func (m *Memory) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
 	values := make([]interface{}, 0, len(keys))
 	for _, key := range keys {
-		value, ok := m.cache.Load(m.namespaceKey(key))
-		if ok {
-			if !m.checkAndDelete(key, value) {
-				values = append(values, value)
-			}
-		}
+		value, _ := m.Get(ctx, key)
+		values = append(values, value)
 	}
 	return values, nil
 }
 
+func (m *Memory) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	for key, value := range values {
+		err := m.Set(ctx, key, value, expiration)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *Memory) Remove(_ context.Context, key ...string) error {
 	for _, value := range key {
-		m.cache.Delete(m.namespaceKey(value))
-		if m.MaxEntries > 0 {
+		// 不存在的key不能减少计数
+		if _, loaded := m.cache.LoadAndDelete(m.namespaceKey(value)); loaded && m.MaxEntries > 0 {
 			atomic.AddInt32(&m.entriesNum, -1)
 		}
 	}
+	m.tags.Delete(key...)
 	return nil
 }
 
+// Tag 给keys加上tags，过期时间由key自己决定
+func (m *Memory) Tag(_ context.Context, tags []string, _ time.Duration, keys ...string) error {
+	m.tags.Add(tags, keys...)
+	return nil
+}
+
+// InvalidateTags 删除tags下所有的key，返回删除的key
+func (m *Memory) InvalidateTags(ctx context.Context, tags ...string) ([]string, error) {
+	keys := m.tags.Take(tags...)
+	return keys, m.Remove(ctx, keys...)
+}
+
+// Flush 删除所有的数据
+func (m *Memory) Flush() {
+	m.cache.Range(func(key, value interface{}) bool {
+		if _, loaded := m.cache.LoadAndDelete(key); loaded && m.MaxEntries > 0 {
+			atomic.AddInt32(&m.entriesNum, -1)
+		}
+		return true
+	})
+	m.tags.Reset()
+}
+
+// NamespaceVersion 返回进程内namespace的版本号
+func (m *Memory) NamespaceVersion(_ context.Context) (int64, error) {
+	return atomic.LoadInt64(&m.version), nil
+}
+
+// IncrNamespaceVersion 增加版本号，旧版本的数据不会再被读到，所以同时清空缓存(避免缓存已满之后无法写入)
+func (m *Memory) IncrNamespaceVersion(_ context.Context) (int64, error) {
+	m.Flush()
+	return atomic.AddInt64(&m.version, 1), nil
+}
+
+// key为带namespace的key
 func (m *Memory) checkAndDelete(key string, value interface{}) bool {
-	key = m.namespaceKey(key)
 	data := value.(*entry)
-	if time.Now().UnixNano() > data.expire {
+	if data.expire > 0 && time.Now().UnixNano() > data.expire {
 		m.cache.Delete(key)
 		if m.MaxEntries > 0 {
 			atomic.AddInt32(&m.entriesNum, -1)
 		}
+		if m.namespace != "" {
+			key = strings.TrimPrefix(key, m.namespace+":")
+		}
+		m.tags.Delete(key)
+		if m.onEvict != nil {
+			m.onEvict(key)
+		}
 		return true
 	}
 	return false
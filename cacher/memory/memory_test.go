@@ -64,3 +64,18 @@ func TestNewMemoryCache2(t *testing.T) {
 	assert.Equal(t, int64(10), haveNum)
 	wait.Wait()
 }
+
+func TestMemory_MGet(t *testing.T) {
+	m := NewMemoryCache(10)
+	m.SetNamespace("test")
+
+	err := m.MSet(context.Background(), map[string]interface{}{
+		"name":  "value",
+		"name1": "value1",
+	}, time.Minute)
+	assert.Nil(t, err)
+
+	values, err := m.MGet(context.Background(), "name", "name2", "name1")
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"value", nil, "value1"}, values)
+}
@@ -2,28 +2,66 @@ package redis
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/liyanbing/go-cache/errors"
+	"github.com/liyanbing/go-cache/logger"
 )
 
+// 只有持有锁(token相同)时才删除
+var unlockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0
+`)
+
+// 把keys加入tag的集合，集合的过期时间只会延长(新建的集合PTTL为-1，需要设置过期时间)；ARGV[1]为过期时间(ms)，0表示不过期
+var tagScript = redis.NewScript(`
+redis.call("sadd", KEYS[1], unpack(ARGV, 2))
+local expiration = tonumber(ARGV[1])
+if expiration <= 0 then
+	redis.call("persist", KEYS[1])
+	return 1
+end
+local ttl = redis.call("pttl", KEYS[1])
+if ttl == -1 or ttl < expiration then
+	redis.call("pexpire", KEYS[1], expiration)
+end
+return 1
+`)
+
 func NewRedisCache(cli redis.Cmdable) *Redis {
 	return &Redis{
-		cli: cli,
+		cli:    cli,
+		logger: logger.Nop(),
 	}
 }
 
 type Redis struct {
 	cli       redis.Cmdable
 	namespace string
+	logger    logger.Logger
+}
+
+// SetLogger 设置日志，执行redis命令失败时输出debug日志
+func (s *Redis) SetLogger(l logger.Logger) {
+	s.logger = l
 }
 
 func (s *Redis) SetNamespace(namespace string) {
 	s.namespace = namespace
 }
 
+func (s *Redis) Namespace() string {
+	return s.namespace
+}
+
 func (s *Redis) namespaceKey(key string) string {
 	if s.namespace == "" {
 		return key
@@ -32,18 +70,16 @@ func (s *Redis) namespaceKey(key string) string {
 }
 
 func (s *Redis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	key = s.namespaceKey(key)
-	return s.cli.Set(ctx, key, value, expiration).Err()
+	return s.wrapError(key, s.cli.Set(ctx, s.namespaceKey(key), value, expiration).Err())
 }
 
 func (s *Redis) Get(ctx context.Context, key string) (interface{}, error) {
-	key = s.namespaceKey(key)
-	value, err := s.cli.Get(ctx, key).Bytes()
+	value, err := s.cli.Get(ctx, s.namespaceKey(key)).Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, errors.ErrEmptyCache
 		}
-		return nil, err
+		return nil, s.wrapError(key, err)
 	}
 	return value, nil
 }
@@ -59,15 +95,168 @@ func (s *Redis) MGet(ctx context.Context, keys ...string) ([]interface{}, error)
 		if err == redis.Nil {
 			return nil, errors.ErrEmptyCache
 		}
-		return nil, err
+		return nil, s.wrapError(strings.Join(keys, ","), err)
 	}
 	return value, nil
 }
 
+// MSet 通过pipeline批量写入
+func (s *Redis) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	pipe := s.cli.Pipeline()
+	for key, value := range values {
+		pipe.Set(ctx, s.namespaceKey(key), value, expiration)
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		return s.wrapError(strings.Join(keys, ","), err)
+	}
+	return nil
+}
+
 func (s *Redis) Remove(ctx context.Context, key ...string) error {
 	keys := make([]string, 0, len(key))
 	for _, value := range key {
 		keys = append(keys, s.namespaceKey(value))
 	}
-	return s.cli.Del(ctx, keys...).Err()
+	return s.wrapError(strings.Join(key, ","), s.cli.Del(ctx, keys...).Err())
+}
+
+// 缓存自己使用的key(锁、tag、版本号)都在namespace:__gocache__:下面，业务的key不应该以__gocache__:开头
+const reservedSegment = "__gocache__"
+
+func (s *Redis) reservedKey(kind string, name string) string {
+	if name == "" {
+		return s.namespaceKey(fmt.Sprintf("%v:%v", reservedSegment, kind))
+	}
+	return s.namespaceKey(fmt.Sprintf("%v:%v:%v", reservedSegment, kind, name))
+}
+
+// 锁的key为namespace:__gocache__:lock:key，不会和业务的key(例如foo:lock)冲突
+func (s *Redis) lockKey(key string) string {
+	return s.reservedKey("lock", key)
+}
+
+// Lock 获取key的填充锁(SET NX PX)，获取成功时返回的token用于释放锁
+func (s *Redis) Lock(ctx context.Context, key string, expiration time.Duration) (string, bool, error) {
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", false, err
+	}
+
+	token := hex.EncodeToString(buf)
+	ok, err := s.cli.SetNX(ctx, s.lockKey(key), token, expiration).Result()
+	if err != nil {
+		return "", false, s.wrapError(key, err)
+	}
+	return token, ok, nil
+}
+
+// Unlock 释放key的填充锁，锁已经过期或者被其他人持有时什么都不做
+func (s *Redis) Unlock(ctx context.Context, key string, token string) error {
+	return s.wrapError(key, unlockScript.Run(ctx, s.cli, []string{s.lockKey(key)}, token).Err())
+}
+
+// 包装成带有key和后端名称的错误，key不带namespace
+func (s *Redis) wrapError(key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	s.logger.Debug("redis command failed", "namespace", s.namespace, "key", key, "err", err)
+	return errors.NewBackendError("redis", key, err)
+}
+
+// tag对应的集合(namespace:__gocache__:tag:tag)，集合中的key不带namespace
+func (s *Redis) tagKey(tag string) string {
+	return s.reservedKey("tag", tag)
+}
+
+// Tag 把keys加入tags对应的集合，集合的过期时间不短于expiration
+func (s *Redis) Tag(ctx context.Context, tags []string, expiration time.Duration, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(keys)+1)
+	args = append(args, expiration.Milliseconds())
+	for _, key := range keys {
+		args = append(args, key)
+	}
+
+	// pipeline中不能处理NOSCRIPT，直接使用EVAL
+	pipe := s.cli.Pipeline()
+	for _, tag := range tags {
+		tagScript.Eval(ctx, pipe, []string{s.tagKey(tag)}, args...)
+	}
+	_, err := pipe.Exec(ctx)
+	return s.wrapError(strings.Join(keys, ","), err)
+}
+
+// InvalidateTags 删除tags下所有的key和tags对应的集合，返回删除的key
+func (s *Redis) InvalidateTags(ctx context.Context, tags ...string) ([]string, error) {
+	seen := make(map[string]struct{})
+	keys := make([]string, 0)
+	tagKeys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tagKey := s.tagKey(tag)
+		members, err := s.cli.SMembers(ctx, tagKey).Result()
+		if err != nil {
+			return nil, s.wrapError(tag, err)
+		}
+		tagKeys = append(tagKeys, tagKey)
+		for _, key := range members {
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	delKeys := make([]string, 0, len(keys)+len(tagKeys))
+	for _, key := range keys {
+		delKeys = append(delKeys, s.namespaceKey(key))
+	}
+	delKeys = append(delKeys, tagKeys...)
+	if len(delKeys) == 0 {
+		return keys, nil
+	}
+	err := s.cli.Del(ctx, delKeys...).Err()
+	if err != nil {
+		return nil, s.wrapError(strings.Join(tags, ","), err)
+	}
+	return keys, nil
+}
+
+// namespace的版本号(namespace:__gocache__:version)
+func (s *Redis) versionKey() string {
+	return s.reservedKey("version", "")
+}
+
+// NamespaceVersion 返回namespace当前的版本号，没有增加过时为0
+func (s *Redis) NamespaceVersion(ctx context.Context) (int64, error) {
+	version, err := s.cli.Get(ctx, s.versionKey()).Int64()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, nil
+		}
+		return 0, s.wrapError(reservedSegment+":version", err)
+	}
+	return version, nil
+}
+
+// IncrNamespaceVersion 增加namespace的版本号，返回增加之后的版本号
+func (s *Redis) IncrNamespaceVersion(ctx context.Context) (int64, error) {
+	version, err := s.cli.Incr(ctx, s.versionKey()).Result()
+	if err != nil {
+		return 0, s.wrapError(reservedSegment+":version", err)
+	}
+	return version, nil
 }
@@ -55,3 +55,140 @@ func TestNewRedisCache(t *testing.T) {
 	assert.Equal(t, errors.ErrEmptyCache, err)
 	assert.Nil(t, value)
 }
+
+func TestRedis_MGet(t *testing.T) {
+	redisCli := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+		DB:   0,
+	})
+
+	cache := NewRedisCache(redisCli)
+	cache.SetNamespace("test")
+
+	err := cache.MSet(context.Background(), map[string]interface{}{
+		"mname":  "value",
+		"mname1": "value1",
+	}, time.Second)
+	assert.Nil(t, err)
+
+	values, err := cache.MGet(context.Background(), "mname", "mname2", "mname1")
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"value", nil, "value1"}, values)
+
+	err = cache.Remove(context.Background(), "mname", "mname1")
+	assert.Nil(t, err)
+}
+
+func TestRedis_Lock(t *testing.T) {
+	redisCli := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+		DB:   0,
+	})
+
+	cache := NewRedisCache(redisCli)
+	cache.SetNamespace("test")
+
+	token, ok, err := cache.Lock(context.Background(), "lock", time.Second)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// 已经被持有
+	_, ok, err = cache.Lock(context.Background(), "lock", time.Second)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	// token不对不能释放
+	err = cache.Unlock(context.Background(), "lock", "other")
+	assert.Nil(t, err)
+	_, ok, err = cache.Lock(context.Background(), "lock", time.Second)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	err = cache.Unlock(context.Background(), "lock", token)
+	assert.Nil(t, err)
+	token, ok, err = cache.Lock(context.Background(), "lock", time.Second)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	err = cache.Unlock(context.Background(), "lock", token)
+	assert.Nil(t, err)
+
+	// 锁的key不会和业务的key冲突
+	ctx := context.Background()
+	assert.Equal(t, "test:__gocache__:lock:foo", cache.lockKey("foo"))
+	assert.Nil(t, cache.Set(ctx, "foo:lock", "value", time.Second))
+	token, ok, err = cache.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	value, err := cache.Get(ctx, "foo:lock")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), value)
+	assert.Nil(t, cache.Unlock(ctx, "foo", token))
+	assert.Nil(t, cache.Remove(ctx, "foo:lock"))
+}
+
+func TestRedis_InvalidateTags(t *testing.T) {
+	ast := assert.New(t)
+	redisCli := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+		DB:   0,
+	})
+	cache := NewRedisCache(redisCli)
+	cache.SetNamespace("test_tags")
+	ctx := context.Background()
+
+	ast.Nil(cache.Set(ctx, "a", "1", time.Minute))
+	ast.Nil(cache.Set(ctx, "b", "2", time.Minute))
+	ast.Nil(cache.Set(ctx, "c", "3", time.Minute))
+	ast.Nil(cache.Tag(ctx, []string{"x"}, time.Minute, "a", "b"))
+	ast.Nil(cache.Tag(ctx, []string{"y"}, time.Minute, "b"))
+
+	// 新建的tag集合也要有过期时间
+	ttl, err := redisCli.PTTL(ctx, cache.tagKey("x")).Result()
+	ast.Nil(err)
+	ast.True(ttl > 0 && ttl <= time.Minute, ttl)
+
+	keys, err := cache.InvalidateTags(ctx, "x", "y")
+	ast.Nil(err)
+	ast.ElementsMatch([]string{"a", "b"}, keys)
+
+	_, err = cache.Get(ctx, "a")
+	ast.Equal(errors.ErrEmptyCache, err)
+	_, err = cache.Get(ctx, "b")
+	ast.Equal(errors.ErrEmptyCache, err)
+	value, err := cache.Get(ctx, "c")
+	ast.Nil(err)
+	ast.Equal([]byte("3"), value)
+
+	// tag已经删除
+	keys, err = cache.InvalidateTags(ctx, "x")
+	ast.Nil(err)
+	ast.Empty(keys)
+	ast.Nil(cache.Remove(ctx, "c"))
+}
+
+func TestRedis_ReservedKeys(t *testing.T) {
+	ast := assert.New(t)
+	redisCli := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+		DB:   0,
+	})
+	cache := NewRedisCache(redisCli)
+	cache.SetNamespace("test_reserved")
+	ctx := context.Background()
+	defer redisCli.Del(ctx, cache.versionKey())
+
+	ast.Equal("test_reserved:__gocache__:tag:x", cache.tagKey("x"))
+	ast.Equal("test_reserved:__gocache__:version", cache.versionKey())
+
+	// 业务的key不会覆盖版本号和tag
+	version, err := cache.IncrNamespaceVersion(ctx)
+	ast.Nil(err)
+	ast.Nil(cache.Set(ctx, "__version", "value", time.Minute))
+	ast.Nil(cache.Set(ctx, "__tag:x", "value", time.Minute))
+	current, err := cache.NamespaceVersion(ctx)
+	ast.Nil(err)
+	ast.Equal(version, current)
+	ast.Nil(cache.Tag(ctx, []string{"x"}, time.Minute, "a"))
+	ast.Nil(cache.Remove(ctx, "__version", "__tag:x"))
+}
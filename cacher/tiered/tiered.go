@@ -0,0 +1,295 @@
+package tiered
+
+import (
+	"context"
+	"time"
+
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/liyanbing/go-cache/logger"
+)
+
+/**
+ * 两级缓存：L1为进程内的缓存(lru、memory)，L2为redis等共享的缓存
+ * 1、先读L1，L1中不存在时读L2，L2中的数据会写入L1(过期时间不超过l1TTL)
+ * 2、写入时先写L2再写L1
+ * 3、删除时L1和L2都会删除
+ */
+
+// Cache 和go_cache.Cache一样，tiered不能依赖go_cache包
+type Cache interface {
+	SetNamespace(namespace string)
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	Get(ctx context.Context, key string) (interface{}, error)
+	MGet(ctx context.Context, keys ...string) ([]interface{}, error)
+	Remove(ctx context.Context, key ...string) error
+}
+
+type namespacer interface {
+	Namespace() string
+}
+
+type multiSetter interface {
+	MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error
+}
+
+// 批量写入，cache没有实现MSet时依次调用Set
+func mset(ctx context.Context, cache Cache, values map[string]interface{}, expiration time.Duration) error {
+	if m, ok := cache.(multiSetter); ok {
+		return m.MSet(ctx, values, expiration)
+	}
+	for key, value := range values {
+		if err := cache.Set(ctx, key, value, expiration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type tagger interface {
+	Tag(ctx context.Context, tags []string, expiration time.Duration, keys ...string) error
+	InvalidateTags(ctx context.Context, tags ...string) ([]string, error)
+}
+
+type versioner interface {
+	NamespaceVersion(ctx context.Context) (int64, error)
+	IncrNamespaceVersion(ctx context.Context) (int64, error)
+}
+
+type locker interface {
+	Lock(ctx context.Context, key string, expiration time.Duration) (string, bool, error)
+	Unlock(ctx context.Context, key string, token string) error
+}
+
+const defaultL1TTL = time.Minute
+
+type Tiered struct {
+	l1    Cache
+	l2    Cache
+	l1TTL time.Duration
+}
+
+// NewTieredCache l1TTL为数据在L1中最长的过期时间，小于等于0时为1分钟
+func NewTieredCache(l1, l2 Cache, l1TTL time.Duration) *Tiered {
+	if l1TTL <= 0 {
+		l1TTL = defaultL1TTL
+	}
+	return &Tiered{
+		l1:    l1,
+		l2:    l2,
+		l1TTL: l1TTL,
+	}
+}
+
+func (t *Tiered) L1() Cache {
+	return t.l1
+}
+
+func (t *Tiered) L2() Cache {
+	return t.l2
+}
+
+func (t *Tiered) SetNamespace(namespace string) {
+	t.l1.SetNamespace(namespace)
+	t.l2.SetNamespace(namespace)
+}
+
+// Namespace 优先返回L2的namespace，L1、L2都没有实现Namespace时返回""
+func (t *Tiered) Namespace() string {
+	for _, c := range []Cache{t.l2, t.l1} {
+		if n, ok := c.(namespacer); ok {
+			return n.Namespace()
+		}
+	}
+	return ""
+}
+
+// 写入L1的过期时间，0表示不过期
+func (t *Tiered) l1Expiration(expiration time.Duration) time.Duration {
+	if expiration <= 0 || expiration > t.l1TTL {
+		return t.l1TTL
+	}
+	return expiration
+}
+
+func (t *Tiered) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	err := t.l2.Set(ctx, key, value, expiration)
+	if err != nil {
+		// L1中的旧数据不能再使用
+		_ = t.l1.Remove(ctx, key)
+		return err
+	}
+	return t.l1.Set(ctx, key, value, t.l1Expiration(expiration))
+}
+
+func (t *Tiered) Get(ctx context.Context, key string) (interface{}, error) {
+	value, err := t.l1.Get(ctx, key)
+	if err == nil {
+		return value, nil
+	}
+
+	value, err = t.l2.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// L2中的数据写入L1，不知道L2中剩余的过期时间，所以只保留l1TTL
+	_ = t.l1.Set(ctx, key, value, t.l1TTL)
+	return value, nil
+}
+
+func (t *Tiered) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	values, err := t.l1.MGet(ctx, keys...)
+	if (err != nil && err != errors.ErrEmptyCache) || len(values) != len(keys) {
+		values = make([]interface{}, len(keys))
+	}
+
+	missing := make([]string, 0, len(keys))
+	for i, key := range keys {
+		if values[i] == nil {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	l2Values, err := t.l2.MGet(ctx, missing...)
+	if err != nil && err != errors.ErrEmptyCache {
+		return nil, err
+	}
+
+	promote := make(map[string]interface{}, len(missing))
+	for i, j := 0, 0; i < len(keys) && j < len(l2Values); i++ {
+		if values[i] != nil {
+			continue
+		}
+		if l2Values[j] != nil {
+			values[i] = l2Values[j]
+			promote[keys[i]] = l2Values[j]
+		}
+		j++
+	}
+
+	if len(promote) > 0 {
+		_ = mset(ctx, t.l1, promote, t.l1TTL)
+	}
+	return values, nil
+}
+
+func (t *Tiered) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	err := mset(ctx, t.l2, values, expiration)
+	if err != nil {
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		_ = t.l1.Remove(ctx, keys...)
+		return err
+	}
+	return mset(ctx, t.l1, values, t.l1Expiration(expiration))
+}
+
+func (t *Tiered) Remove(ctx context.Context, key ...string) error {
+	l1Err := t.l1.Remove(ctx, key...)
+	err := t.l2.Remove(ctx, key...)
+	if err != nil {
+		return err
+	}
+	return l1Err
+}
+
+// Lock 使用L2的填充锁，L2不支持时直接获取成功(相当于没有锁)
+func (t *Tiered) Lock(ctx context.Context, key string, expiration time.Duration) (string, bool, error) {
+	if l, ok := t.l2.(locker); ok {
+		return l.Lock(ctx, key, expiration)
+	}
+	return "", true, nil
+}
+
+func (t *Tiered) Unlock(ctx context.Context, key string, token string) error {
+	if l, ok := t.l2.(locker); ok {
+		return l.Unlock(ctx, key, token)
+	}
+	return nil
+}
+
+// Tag L1和L2都会记录tag，L1中的过期时间不超过l1TTL
+func (t *Tiered) Tag(ctx context.Context, tags []string, expiration time.Duration, keys ...string) error {
+	if l1, ok := t.l1.(tagger); ok {
+		err := l1.Tag(ctx, tags, t.l1Expiration(expiration), keys...)
+		if err != nil {
+			return err
+		}
+	}
+	if l2, ok := t.l2.(tagger); ok {
+		return l2.Tag(ctx, tags, expiration, keys...)
+	}
+	return nil
+}
+
+// InvalidateTags 删除L1和L2中tags下所有的key，L2中删除的key也会从L1中删除(可能是从L2写入L1的数据)
+func (t *Tiered) InvalidateTags(ctx context.Context, tags ...string) ([]string, error) {
+	var keys []string
+	if l2, ok := t.l2.(tagger); ok {
+		l2Keys, err := l2.InvalidateTags(ctx, tags...)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, l2Keys...)
+	}
+
+	if l1, ok := t.l1.(tagger); ok {
+		l1Keys, err := l1.InvalidateTags(ctx, tags...)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, l1Keys...)
+	}
+
+	if len(keys) > 0 {
+		err := t.l1.Remove(ctx, keys...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// NamespaceVersion 使用L2中namespace的版本号，L2不支持时返回errors.ErrNotSupported
+func (t *Tiered) NamespaceVersion(ctx context.Context) (int64, error) {
+	if v, ok := t.l2.(versioner); ok {
+		return v.NamespaceVersion(ctx)
+	}
+	return 0, errors.ErrNotSupported
+}
+
+// IncrNamespaceVersion 增加L2中namespace的版本号，L1中旧版本的数据不会再被读到，L1支持Flush时同时清空L1
+func (t *Tiered) IncrNamespaceVersion(ctx context.Context) (int64, error) {
+	v, ok := t.l2.(versioner)
+	if !ok {
+		return 0, errors.ErrNotSupported
+	}
+	version, err := v.IncrNamespaceVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if l1, ok := t.l1.(interface{ Flush() }); ok {
+		l1.Flush()
+	}
+	return version, nil
+}
+
+// SetEvictHandler L1中的数据被淘汰时调用fn
+func (t *Tiered) SetEvictHandler(fn func(key string)) {
+	if ev, ok := t.l1.(interface{ SetEvictHandler(fn func(key string)) }); ok {
+		ev.SetEvictHandler(fn)
+	}
+}
+
+func (t *Tiered) SetLogger(l logger.Logger) {
+	for _, cache := range []Cache{t.l1, t.l2} {
+		if c, ok := cache.(interface{ SetLogger(logger.Logger) }); ok {
+			c.SetLogger(l)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package tiered
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/stretchr/testify/assert"
+
+	redis "github.com/go-redis/redis/v8"
+	redisCache "github.com/liyanbing/go-cache/cacher/redis"
+)
+
+func TestNewTieredCache(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	l1 := lru.NewLRU(10)
+	l2 := redisCache.NewRedisCache(redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+		DB:   0,
+	}))
+	cache := NewTieredCache(l1, l2, time.Millisecond*100)
+	cache.SetNamespace("tiered")
+	ast.Equal("tiered", l1.Namespace())
+	ast.Equal("tiered", l2.Namespace())
+
+	// 写入L1和L2
+	err := cache.Set(ctx, "name", "value", time.Second)
+	ast.Nil(err)
+	value, err := l1.Get(ctx, "name")
+	ast.Nil(err)
+	ast.Equal("value", value)
+	value, err = l2.Get(ctx, "name")
+	ast.Nil(err)
+	ast.Equal([]byte("value"), value)
+
+	// L1中的数据过期之后从L2中获取，并写回L1
+	time.Sleep(time.Millisecond * 150)
+	_, err = l1.Get(ctx, "name")
+	ast.Equal(errors.ErrEmptyCache, err)
+	value, err = cache.Get(ctx, "name")
+	ast.Nil(err)
+	ast.Equal([]byte("value"), value)
+	value, err = l1.Get(ctx, "name")
+	ast.Nil(err)
+	ast.Equal([]byte("value"), value)
+
+	// MGet
+	err = l2.Set(ctx, "age", "10", time.Second)
+	ast.Nil(err)
+	values, err := cache.MGet(ctx, "name", "age", "empty")
+	ast.Nil(err)
+	ast.Equal([]interface{}{[]byte("value"), "10", nil}, values)
+	value, err = l1.Get(ctx, "age")
+	ast.Nil(err)
+	ast.Equal("10", value)
+
+	// 删除L1和L2
+	err = cache.Remove(ctx, "name", "age")
+	ast.Nil(err)
+	_, err = cache.Get(ctx, "name")
+	ast.Equal(errors.ErrEmptyCache, err)
+	_, err = l2.Get(ctx, "age")
+	ast.Equal(errors.ErrEmptyCache, err)
+
+	// 填充锁使用L2
+	token, ok, err := cache.Lock(ctx, "name", time.Second)
+	ast.Nil(err)
+	ast.True(ok)
+	_, ok, err = cache.Lock(ctx, "name", time.Second)
+	ast.Nil(err)
+	ast.False(ok)
+	ast.Nil(cache.Unlock(ctx, "name", token))
+}
@@ -0,0 +1,202 @@
+package go_cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/liyanbing/go-cache/tools"
+)
+
+/**
+ * 可以扩展的序列化方式
+ * 1、实现Codec接口之后通过FetchWithCodec使用，不需要复制fetch的逻辑
+ * 2、Codec可以通过RegisterCodec按名称注册，通过LookupCodec查找，内置json、protobuf、string、number和gob
+ * 3、压缩、加密等对编码之后的数据的处理对所有的Codec都生效
+ */
+
+// 内置Codec的名称
+const (
+	CodecJson     = "json"
+	CodecProtobuf = "protobuf"
+	CodecString   = "string"
+	CodecNumber   = "number"
+	CodecGob      = "gob"
+)
+
+// Codec 负责缓存数据和model之间的转换
+type Codec interface {
+	// Name 注册时使用的名称
+	Name() string
+	// Encode 把fetcher返回的数据编码之后写入缓存
+	Encode(value interface{}) ([]byte, error)
+	// Decode 把缓存中的数据解码成model的类型，model可以是对象或者对象指针，返回对象指针
+	Decode(data []byte, model interface{}) (interface{}, error)
+}
+
+var codecs sync.Map // name -> Codec
+
+func init() {
+	for _, c := range []Codec{jsonModelCodec{}, protobufModelCodec{}, stringModelCodec{}, numberModelCodec{}, gobModelCodec{}} {
+		RegisterCodec(c)
+	}
+}
+
+// RegisterCodec 按照c.Name()注册Codec，名称相同时会覆盖之前注册的Codec(包括内置的)
+func RegisterCodec(c Codec) {
+	codecs.Store(c.Name(), c)
+}
+
+// LookupCodec 返回名称为name的Codec
+func LookupCodec(name string) (Codec, bool) {
+	c, ok := codecs.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return c.(Codec), true
+}
+
+func codecEncoder(c Codec) encoder {
+	return c.Encode
+}
+
+// 和JsonDecode一样，不是[]byte或者string的数据(进程内的缓存通过Set写入的对象)原样返回
+func codecDecoder(c Codec, model interface{}) Decoder {
+	return func(data interface{}) (interface{}, error) {
+		byteData, ok := toBytes(data)
+		if !ok {
+			return data, nil
+		}
+		return c.Decode(byteData, model)
+	}
+}
+
+type jsonModelCodec struct{}
+
+func (jsonModelCodec) Name() string {
+	return CodecJson
+}
+
+func (jsonModelCodec) Encode(value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSchema(reflect.TypeOf(value), data), nil
+}
+
+func (jsonModelCodec) Decode(data []byte, model interface{}) (interface{}, error) {
+	data, err := decodeSchema(typeFromModel(model), data)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := reflect.New(typeFromModel(model))
+	err = json.Unmarshal(data, ret.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return ret.Interface(), nil
+}
+
+type protobufModelCodec struct{}
+
+func (protobufModelCodec) Name() string {
+	return CodecProtobuf
+}
+
+func (protobufModelCodec) Encode(value interface{}) ([]byte, error) {
+	mes, ok := value.(proto.Message)
+	if !ok {
+		return nil, errors.ErrInvalidValue
+	}
+	data, err := proto.Marshal(mes)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSchema(reflect.TypeOf(value), data), nil
+}
+
+func (protobufModelCodec) Decode(data []byte, model interface{}) (interface{}, error) {
+	ret, ok := reflect.New(typeFromModel(model)).Interface().(proto.Message)
+	if !ok {
+		return nil, errors.ErrInvalidValue
+	}
+	data, err := decodeSchema(typeFromModel(model), data)
+	if err != nil {
+		return nil, err
+	}
+	err = proto.Unmarshal(data, ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// string和[]byte按原样保存，Decode返回string，不需要model
+type stringModelCodec struct{}
+
+func (stringModelCodec) Name() string {
+	return CodecString
+}
+
+func (stringModelCodec) Encode(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	}
+	return nil, errors.ErrInvalidValue
+}
+
+func (stringModelCodec) Decode(data []byte, _ interface{}) (interface{}, error) {
+	return string(data), nil
+}
+
+// 数字按照字符串保存，Decode返回float64，不需要model
+type numberModelCodec struct{}
+
+func (numberModelCodec) Name() string {
+	return CodecNumber
+}
+
+func (numberModelCodec) Encode(value interface{}) ([]byte, error) {
+	if !tools.CanConvertToNumber(value) {
+		return nil, errors.ErrInvalidValue
+	}
+	return []byte(fmt.Sprintf("%v", value)), nil
+}
+
+func (numberModelCodec) Decode(data []byte, _ interface{}) (interface{}, error) {
+	return tools.ToFloat(data)
+}
+
+// gob编解码，接口类型的字段需要先通过gob.Register注册
+type gobModelCodec struct{}
+
+func (gobModelCodec) Name() string {
+	return CodecGob
+}
+
+func (gobModelCodec) Encode(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobModelCodec) Decode(data []byte, model interface{}) (interface{}, error) {
+	ret := reflect.New(typeFromModel(model))
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(ret.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return ret.Interface(), nil
+}
@@ -0,0 +1,92 @@
+package go_cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 用于测试的Codec，把字符串保存为大写
+type upperCodec struct{}
+
+func (upperCodec) Name() string {
+	return "upper"
+}
+
+func (upperCodec) Encode(value interface{}) ([]byte, error) {
+	return []byte(strings.ToUpper(value.(string))), nil
+}
+
+func (upperCodec) Decode(data []byte, _ interface{}) (interface{}, error) {
+	return string(data), nil
+}
+
+func TestLookupCodec(t *testing.T) {
+	ast := assert.New(t)
+
+	for _, name := range []string{CodecJson, CodecProtobuf, CodecString, CodecNumber, CodecGob} {
+		c, ok := LookupCodec(name)
+		ast.True(ok)
+		ast.Equal(name, c.Name())
+	}
+
+	_, ok := LookupCodec("upper")
+	ast.False(ok)
+	RegisterCodec(upperCodec{})
+	c, ok := LookupCodec("upper")
+	ast.True(ok)
+	ast.Equal(upperCodec{}, c)
+}
+
+func TestFetchWithCodec(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	bridge := MustNewBridge(WithLRU(10))
+
+	cases := []struct {
+		codec string
+		value interface{}
+		model interface{}
+		want  interface{}
+	}{
+		{CodecJson, &TempModel{Name: "peter", Age: 23}, TempModel{}, &TempModel{Name: "peter", Age: 23}},
+		{CodecGob, &TempModel{Name: "peter", Age: 23}, &TempModel{}, &TempModel{Name: "peter", Age: 23}},
+		{CodecProtobuf, &TempModelPb{IsMember: true, ExpireAt: 101}, TempModelPb{}, &TempModelPb{IsMember: true, ExpireAt: 101}},
+		{CodecString, "abc", nil, "abc"},
+		{CodecNumber, 12, nil, float64(12)},
+	}
+	for _, c := range cases {
+		codec, ok := LookupCodec(c.codec)
+		ast.True(ok)
+
+		fetches := 0
+		fetcher := func() (interface{}, time.Duration, error) {
+			fetches++
+			return c.value, time.Minute, nil
+		}
+		value, err := bridge.FetchWithCodec(ctx, "codec-"+c.codec, fetcher, codec, c.model)
+		ast.Nil(err)
+		ast.Equal(c.value, value)
+
+		// 从缓存中解码
+		value, err = bridge.FetchWithCodec(ctx, "codec-"+c.codec, fetcher, codec, c.model)
+		ast.Nil(err, c.codec)
+		ast.Equal(c.want, value, c.codec)
+		ast.Equal(1, fetches)
+	}
+
+	// 自定义的Codec
+	value, err := FetchWithCodec(ctx, bridge, "codec-upper", func() (interface{}, time.Duration, error) {
+		return "abc", time.Minute, nil
+	}, upperCodec{}, nil)
+	ast.Nil(err)
+	ast.Equal("abc", value)
+	value, err = FetchWithCodec(ctx, bridge, "codec-upper", func() (interface{}, time.Duration, error) {
+		return nil, 0, assert.AnError
+	}, upperCodec{}, nil)
+	ast.Nil(err)
+	ast.Equal("ABC", value)
+}
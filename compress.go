@@ -0,0 +1,151 @@
+package go_cache
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/liyanbing/go-cache/errors"
+)
+
+/**
+ * 压缩编码之后的数据
+ * 1、编码之后的数据不小于minSize时才压缩，压缩之后没有变小时保存原始数据
+ * 2、压缩之后的数据总是带有元数据(item)，压缩算法的ID记录在元数据中，普通的数据不会被当成压缩之后的数据
+ * 3、元数据中没有压缩算法的数据按原样解码，所以开启和关闭压缩都不需要清空缓存
+ */
+
+const (
+	// 没有压缩
+	compressNone  byte = 0
+	compressGzip  byte = 1
+	compressFlate byte = 2
+)
+
+// Compressor 压缩算法，ID会写入压缩之后的数据中，解压时根据ID选择算法；0-15为内置算法保留
+type Compressor interface {
+	ID() byte
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var compressors sync.Map // ID -> Compressor
+
+func init() {
+	RegisterCompressor(Gzip(gzip.DefaultCompression))
+	RegisterCompressor(Flate(flate.DefaultCompression))
+}
+
+// RegisterCompressor 注册解压时使用的算法，WithCompression会自动注册；
+// 只读取其他进程压缩的数据(例如snappy、zstd)时需要先注册
+func RegisterCompressor(c Compressor) {
+	compressors.Store(c.ID(), c)
+}
+
+type gzipCompressor struct {
+	level int
+}
+
+// Gzip 标准库的gzip，level为gzip.DefaultCompression等
+func Gzip(level int) Compressor {
+	return &gzipCompressor{level: level}
+}
+
+func (c *gzipCompressor) ID() byte {
+	return compressGzip
+}
+
+func (c *gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+type flateCompressor struct {
+	level int
+}
+
+// Flate 标准库的flate(没有gzip的头部和校验)，level为flate.DefaultCompression等
+func Flate(level int) Compressor {
+	return &flateCompressor{level: level}
+}
+
+func (c *flateCompressor) ID() byte {
+	return compressFlate
+}
+
+func (c *flateCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *flateCompressor) Decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+type compression struct {
+	compressor Compressor
+	minSize    int
+}
+
+// 返回压缩算法的ID和压缩之后的数据，没有压缩时ID为compressNone
+func (c *compression) compress(data []byte) (byte, []byte, error) {
+	if len(data) < c.minSize {
+		return compressNone, data, nil
+	}
+
+	compressed, err := c.compressor.Compress(data)
+	if err != nil {
+		return compressNone, nil, err
+	}
+	if len(compressed) >= len(data) {
+		return compressNone, data, nil
+	}
+	return c.compressor.ID(), compressed, nil
+}
+
+// 使用ID为id的算法解压
+func decompress(id byte, data []byte) ([]byte, error) {
+	c, ok := compressors.Load(id)
+	if !ok {
+		return nil, errors.NewDecodeError("", fmt.Errorf("unknown compressor %d", id))
+	}
+	ret, err := c.(Compressor).Decompress(data)
+	if err != nil {
+		return nil, errors.NewDecodeError("", err)
+	}
+	return ret, nil
+}
@@ -0,0 +1,125 @@
+package go_cache
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBridgeCompression(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	for _, c := range []Compressor{Gzip(gzip.BestSpeed), Flate(flate.BestSpeed)} {
+		cache := lru.NewLRU(10)
+		bridge := MustNewBridge(WithCache(cache), WithCompression(c, 64))
+
+		name := strings.Repeat("peter", 100)
+		fetches := 0
+		fetcher := func() (interface{}, time.Duration, error) {
+			fetches++
+			return &TempModel{Name: name, Age: 23}, time.Minute, nil
+		}
+		for i := 0; i < 2; i++ {
+			value, err := bridge.FetchWithJson(ctx, "large", fetcher, &TempModel{})
+			ast.Nil(err)
+			ast.Equal(name, value.(*TempModel).Name)
+		}
+		ast.Equal(1, fetches)
+
+		data, err := cache.Get(ctx, "large")
+		ast.Nil(err)
+		ast.Equal(c.ID(), newItem(data).compressor)
+		ast.Less(len(data.([]byte)), len(name))
+
+		// 小于minSize时不压缩
+		_, err = bridge.FetchWithString(ctx, "small", func() (interface{}, time.Duration, error) {
+			return "abc", time.Minute, nil
+		})
+		ast.Nil(err)
+		data, err = cache.Get(ctx, "small")
+		ast.Nil(err)
+		ast.Equal([]byte("abc"), data)
+
+		// 没有开启压缩的Bridge也可以读取压缩过的数据
+		plain := MustNewBridge(WithCache(cache))
+		value, err := plain.FetchWithJson(ctx, "large", fetcher, &TempModel{})
+		ast.Nil(err)
+		ast.Equal(name, value.(*TempModel).Name)
+		ast.Equal(1, fetches)
+	}
+}
+
+func TestBridgeCompression_Compatible(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	cache := lru.NewLRU(10)
+	bridge := MustNewBridge(WithCache(cache), WithCompression(Gzip(gzip.DefaultCompression), 0))
+
+	// 开启压缩之前写入的数据
+	ast.Nil(cache.Set(ctx, "old", []byte(`{"name":"peter","age":23}`), time.Minute))
+	value, err := bridge.FetchWithJson(ctx, "old", func() (interface{}, time.Duration, error) {
+		return nil, 0, assert.AnError
+	}, &TempModel{})
+	ast.Nil(err)
+	ast.Equal("peter", value.(*TempModel).Name)
+
+}
+
+// 普通的数据不会被当成压缩之后的数据，不管是否开启压缩
+func TestBridgeCompression_PlainValues(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	bridges := []Bridge{
+		MustNewBridge(WithMemory(10)),
+		MustNewBridge(WithMemory(10), WithCompression(Gzip(gzip.DefaultCompression), 0)),
+	}
+	for _, bridge := range bridges {
+		for _, raw := range []string{"\x0f\x00hello", "\x0f\x09hello", "\x0f\x01hello"} {
+			for i := 0; i < 2; i++ {
+				str, err := bridge.FetchWithString(ctx, raw, func() (interface{}, time.Duration, error) {
+					return raw, time.Minute, nil
+				})
+				ast.Nil(err)
+				ast.Equal(raw, str)
+			}
+
+			values, err := bridge.FetchWithKeys(ctx, raw)
+			ast.Nil(err)
+			ast.Equal([]interface{}{[]byte(raw)}, values)
+		}
+	}
+}
+
+func TestFetchMultiCompression(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	bridge := MustNewBridge(WithLRU(10), WithCompression(Gzip(gzip.BestSpeed), 0))
+	name := strings.Repeat("a", 1000)
+	fetcher := func(ctx context.Context, keys []string) (map[string]string, time.Duration, error) {
+		ret := make(map[string]string, len(keys))
+		for _, key := range keys {
+			ret[key] = name
+		}
+		return ret, time.Minute, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		values, err := FetchMulti(ctx, bridge, []string{"a", "b"}, fetcher, StringCodec())
+		ast.Nil(err)
+		ast.Equal(map[string]string{"a": name, "b": name}, values)
+	}
+
+	values, err := FetchWithKeys(ctx, bridge, "a", "b")
+	ast.Nil(err)
+	ast.Equal([]interface{}{[]byte(name), []byte(name)}, values)
+}
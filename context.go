@@ -2,16 +2,115 @@ package go_cache
 
 import (
 	"context"
+	"time"
 )
 
-var (
-	noCache struct{}
+type (
+	policyKey               struct{}
+	maxStaleKey             struct{}
+	staleWhileRevalidateKey struct{}
+	earlyExpirationKey      struct{}
+	staleIfErrorKey         struct{}
+	tagsKey                 struct{}
 )
 
+// Policy 本次调用如何使用缓存
+type Policy int8
+
+const (
+	// PolicyDefault 先读缓存，不存在时调用fetcher并写入缓存
+	PolicyDefault Policy = iota
+	// PolicyReadOnly 先读缓存，不存在时调用fetcher，但是不写入缓存
+	PolicyReadOnly
+	// PolicyRefresh 不读缓存，直接调用fetcher并写入缓存
+	PolicyRefresh
+	// PolicyBypass 不读也不写缓存，直接调用fetcher
+	PolicyBypass
+	// PolicyCacheOnly 只读缓存，不会调用fetcher，不存在时返回errors.ErrEmptyCache
+	PolicyCacheOnly
+)
+
+func (p Policy) canRead() bool {
+	return p != PolicyRefresh && p != PolicyBypass
+}
+
+func (p Policy) canWrite() bool {
+	return p == PolicyDefault || p == PolicyRefresh
+}
+
+func (p Policy) canFetch() bool {
+	return p != PolicyCacheOnly
+}
+
+// WithPolicy 设置本次调用的缓存策略，对fetch和Bridge的所有方法都生效，Bridge的Remove、InvalidateTags除外
+func WithPolicy(ctx context.Context, policy Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, policy)
+}
+
+func policyFromContext(ctx context.Context) Policy {
+	policy, _ := ctx.Value(policyKey{}).(Policy)
+	return policy
+}
+
+// WithNoUseCache 不读缓存，直接从fetcher中获取数据并更新缓存，等同于WithPolicy(ctx, PolicyRefresh)
 func WithNoUseCache(ctx context.Context) context.Context {
-	return context.WithValue(ctx, noCache, struct{}{})
+	return WithPolicy(ctx, PolicyRefresh)
+}
+
+// WithMaxStale 可以接受过期时间不超过maxStale的旧数据(需要数据过期之后仍然保留在缓存中，例如WithStaleIfError)
+func WithMaxStale(ctx context.Context, maxStale time.Duration) context.Context {
+	return context.WithValue(ctx, maxStaleKey{}, maxStale)
+}
+
+func maxStale(ctx context.Context) time.Duration {
+	maxStale, _ := ctx.Value(maxStaleKey{}).(time.Duration)
+	return maxStale
+}
+
+// ContextWithStaleWhileRevalidate 对本次调用生效的WithStaleWhileRevalidate，会覆盖Bridge上的配置
+func ContextWithStaleWhileRevalidate(ctx context.Context, staleTTL time.Duration) context.Context {
+	return context.WithValue(ctx, staleWhileRevalidateKey{}, staleTTL)
+}
+
+func staleWhileRevalidate(ctx context.Context) (time.Duration, bool) {
+	staleTTL, ok := ctx.Value(staleWhileRevalidateKey{}).(time.Duration)
+	return staleTTL, ok
+}
+
+// ContextWithEarlyExpiration 对本次调用生效的WithEarlyExpiration，会覆盖Bridge上的配置，beta为0表示关闭
+func ContextWithEarlyExpiration(ctx context.Context, beta float64) context.Context {
+	return context.WithValue(ctx, earlyExpirationKey{}, beta)
+}
+
+func earlyExpiration(ctx context.Context) (float64, bool) {
+	beta, ok := ctx.Value(earlyExpirationKey{}).(float64)
+	return beta, ok
+}
+
+// ContextWithStaleIfError 对本次调用生效的WithStaleIfError，会覆盖Bridge上的配置
+func ContextWithStaleIfError(ctx context.Context, staleTTL time.Duration) context.Context {
+	return context.WithValue(ctx, staleIfErrorKey{}, staleTTL)
+}
+
+func staleIfError(ctx context.Context) (time.Duration, bool) {
+	staleTTL, ok := ctx.Value(staleIfErrorKey{}).(time.Duration)
+	return staleTTL, ok
+}
+
+// WithTags 通过Bridge写入缓存时(Set、MSet和FetchWith*)给key加上tags，之后可以通过Bridge.InvalidateTags删除；
+// 多次调用时tags会合并
+func WithTags(ctx context.Context, tags ...string) context.Context {
+	if len(tags) == 0 {
+		return ctx
+	}
+	old := tagsFromContext(ctx)
+	merged := make([]string, 0, len(old)+len(tags))
+	merged = append(merged, old...)
+	merged = append(merged, tags...)
+	return context.WithValue(ctx, tagsKey{}, merged)
 }
 
-func noUseCache(ctx context.Context) bool {
-	return ctx.Value(noCache) != nil
+func tagsFromContext(ctx context.Context) []string {
+	tags, _ := ctx.Value(tagsKey{}).([]string)
+	return tags
 }
@@ -0,0 +1,100 @@
+package go_cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchWithPolicy(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	bridge := MustNewBridge(WithLRU(10))
+
+	cnt := int32(0)
+	fetchFunc := func() (interface{}, time.Duration, error) {
+		n := atomic.AddInt32(&cnt, 1)
+		return n, time.Minute, nil
+	}
+
+	// 只读缓存，不存在时返回ErrEmptyCache，不会调用fetcher
+	_, err := bridge.FetchWithNumber(WithPolicy(ctx, PolicyCacheOnly), "policy-key", fetchFunc)
+	ast.Equal(errors.ErrEmptyCache, err)
+	ast.EqualValues(0, atomic.LoadInt32(&cnt))
+
+	// 不写缓存
+	ret, err := bridge.FetchWithNumber(WithPolicy(ctx, PolicyReadOnly), "policy-key", fetchFunc)
+	ast.Nil(err)
+	ast.EqualValues(1, ret)
+	ret, err = bridge.FetchWithNumber(WithPolicy(ctx, PolicyBypass), "policy-key", fetchFunc)
+	ast.Nil(err)
+	ast.EqualValues(2, ret)
+	_, err = bridge.Get(ctx, "policy-key")
+	ast.Equal(errors.ErrEmptyCache, err)
+
+	// 写缓存
+	ret, err = bridge.FetchWithNumber(WithPolicy(ctx, PolicyRefresh), "policy-key", fetchFunc)
+	ast.Nil(err)
+	ast.EqualValues(3, ret)
+	ret, err = bridge.FetchWithNumber(WithPolicy(ctx, PolicyCacheOnly), "policy-key", fetchFunc)
+	ast.Nil(err)
+	ast.EqualValues(3, ret)
+	ret, err = bridge.FetchWithNumber(WithPolicy(ctx, PolicyReadOnly), "policy-key", fetchFunc)
+	ast.Nil(err)
+	ast.EqualValues(3, ret)
+
+	// 不读缓存
+	ret, err = bridge.FetchWithNumber(WithPolicy(ctx, PolicyBypass), "policy-key", fetchFunc)
+	ast.Nil(err)
+	ast.EqualValues(4, ret)
+	ret, err = bridge.FetchWithNumber(ctx, "policy-key", fetchFunc)
+	ast.Nil(err)
+	ast.EqualValues(3, ret)
+
+	// Bridge的方法
+	_, err = bridge.Get(WithPolicy(ctx, PolicyBypass), "policy-key")
+	ast.Equal(errors.ErrEmptyCache, err)
+	_, err = bridge.Get(WithPolicy(ctx, PolicyCacheOnly), "policy-key")
+	ast.Nil(err)
+
+	// 主动删除不受策略影响
+	for _, policy := range []Policy{PolicyReadOnly, PolicyCacheOnly, PolicyBypass} {
+		ast.Nil(bridge.Set(ctx, "policy-key", 5, time.Minute))
+		ast.Nil(bridge.Remove(WithPolicy(ctx, policy), "policy-key"))
+		_, err = bridge.Get(ctx, "policy-key")
+		ast.Equal(errors.ErrEmptyCache, err)
+	}
+}
+
+func TestFetchWithMaxStale(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	bridge := MustNewBridge(WithLRU(10), WithStaleIfError(time.Minute))
+
+	cnt := int32(0)
+	fetchFunc := func() (interface{}, time.Duration, error) {
+		n := atomic.AddInt32(&cnt, 1)
+		return n, time.Millisecond * 10, nil
+	}
+
+	ret, err := bridge.FetchWithNumber(ctx, "max-stale-key", fetchFunc)
+	ast.Nil(err)
+	ast.EqualValues(1, ret)
+
+	// 可以接受旧数据，不会同步调用fetcher
+	time.Sleep(time.Millisecond * 20)
+	ret, err = bridge.FetchWithNumber(WithPolicy(WithMaxStale(ctx, time.Minute), PolicyCacheOnly), "max-stale-key", fetchFunc)
+	ast.Nil(err)
+	ast.EqualValues(1, ret)
+	_, err = bridge.FetchWithNumber(WithPolicy(ctx, PolicyCacheOnly), "max-stale-key", fetchFunc)
+	ast.Equal(errors.ErrEmptyCache, err)
+	ast.EqualValues(1, atomic.LoadInt32(&cnt))
+
+	ret, err = bridge.FetchWithNumber(ctx, "max-stale-key", fetchFunc)
+	ast.Nil(err)
+	ast.EqualValues(2, ret)
+}
@@ -5,6 +5,7 @@ import (
 	"reflect"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/liyanbing/go-cache/errors"
 )
 
 type Decoder func(interface{}) (interface{}, error)
@@ -17,22 +18,33 @@ func typeFromModel(model interface{}) reflect.Type {
 	return typ
 }
 
+func toBytes(data interface{}) ([]byte, bool) {
+	switch data.(type) {
+	case []byte:
+		return data.([]byte), true
+	case string:
+		return []byte(data.(string)), true
+	}
+	return nil, false
+}
+
 func ProtoDecode(model interface{}) Decoder {
 	return func(data interface{}) (interface{}, error) {
-		var byteData []byte
-		switch data.(type) {
-		case []byte:
-			byteData = data.([]byte)
-		case string:
-			byteData = []byte(data.(string))
-		default:
+		data = payloadOf(data)
+		byteData, ok := toBytes(data)
+		if !ok {
 			return data, nil
 		}
 
+		byteData, err := decodeSchema(typeFromModel(model), byteData)
+		if err != nil {
+			return nil, errors.NewDecodeError("", err)
+		}
+
 		ret := reflect.New(typeFromModel(model))
-		err := proto.Unmarshal(byteData, ret.Interface().(proto.Message))
+		err = proto.Unmarshal(byteData, ret.Interface().(proto.Message))
 		if err != nil {
-			return nil, err
+			return nil, errors.NewDecodeError("", err)
 		}
 		return ret.Interface(), nil
 	}
@@ -40,20 +52,21 @@ func ProtoDecode(model interface{}) Decoder {
 
 func JsonDecode(model interface{}) Decoder {
 	return func(data interface{}) (interface{}, error) {
-		var byteData []byte
-		switch data.(type) {
-		case []byte:
-			byteData = data.([]byte)
-		case string:
-			byteData = []byte(data.(string))
-		default:
+		data = payloadOf(data)
+		byteData, ok := toBytes(data)
+		if !ok {
 			return data, nil
 		}
 
+		byteData, err := decodeSchema(typeFromModel(model), byteData)
+		if err != nil {
+			return nil, errors.NewDecodeError("", err)
+		}
+
 		ret := reflect.New(typeFromModel(model))
-		err := json.NewDecoder(bytes.NewBuffer(byteData)).Decode(ret.Interface())
+		err = json.NewDecoder(bytes.NewBuffer(byteData)).Decode(ret.Interface())
 		if err != nil {
-			return nil, err
+			return nil, errors.NewDecodeError("", err)
 		}
 		return ret.Interface(), nil
 	}
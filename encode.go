@@ -1,6 +1,8 @@
 package go_cache
 
 import (
+	"reflect"
+
 	"github.com/golang/protobuf/proto"
 	"github.com/liyanbing/go-cache/errors"
 )
@@ -10,11 +12,20 @@ type encoder func(interface{}) ([]byte, error)
 func protoEncode(value interface{}) ([]byte, error) {
 	mes, ok := value.(proto.Message)
 	if !ok {
-		return nil, errors.ErrInvalidValue
+		return nil, errors.NewEncodeError("", errors.ErrInvalidValue)
+	}
+
+	data, err := proto.Marshal(mes)
+	if err != nil {
+		return nil, errors.NewEncodeError("", err)
 	}
-	return proto.Marshal(mes)
+	return encodeSchema(reflect.TypeOf(value), data), nil
 }
 
 func jsonEncode(value interface{}) ([]byte, error) {
-	return json.Marshal(value)
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.NewEncodeError("", err)
+	}
+	return encodeSchema(reflect.TypeOf(value), data), nil
 }
@@ -0,0 +1,128 @@
+package go_cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/liyanbing/go-cache/errors"
+)
+
+/**
+ * 使用AES-GCM加密编码之后的数据，避免敏感数据(token、个人信息)以明文保存在共享的redis中
+ * 1、加密之后的数据格式为：encryptMagic(1字节) + key ID长度(1字节) + key ID + nonce + 密文，头部作为附加数据参与认证
+ * 2、写入时总是使用当前的key，读取时根据数据中的key ID选择key，所以轮换key时旧的key需要保留到旧数据过期
+ * 3、不带encryptMagic的数据(开启加密之前写入的明文)默认当作解码失败，通过AllowPlaintext()迁移时原样返回
+ */
+
+// encryptMagic(0x17)不会出现在json、数字和protobuf(wire type为7)的开头
+const encryptMagic byte = 0x17
+
+// EncryptionKey AES的key，长度为16、24或32字节，ID会写入加密之后的数据中，长度不超过255
+type EncryptionKey struct {
+	ID  string
+	Key []byte
+}
+
+type Encryptor struct {
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewEncryptor current用于加密，current和old都可以用于解密；key不正确时返回errors.ErrInvalidOption
+func NewEncryptor(current EncryptionKey, old ...EncryptionKey) (*Encryptor, error) {
+	e := &Encryptor{
+		current: current.ID,
+		aeads:   make(map[string]cipher.AEAD, len(old)+1),
+	}
+	for _, key := range append([]EncryptionKey{current}, old...) {
+		if key.ID == "" || len(key.ID) > 255 {
+			return nil, fmt.Errorf("%w: invalid encryption key id %q", errors.ErrInvalidOption, key.ID)
+		}
+		if _, ok := e.aeads[key.ID]; ok {
+			return nil, fmt.Errorf("%w: duplicate encryption key id %q", errors.ErrInvalidOption, key.ID)
+		}
+
+		block, err := aes.NewCipher(key.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: encryption key %q: %v", errors.ErrInvalidOption, key.ID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("%w: encryption key %q: %v", errors.ErrInvalidOption, key.ID, err)
+		}
+		e.aeads[key.ID] = aead
+	}
+	return e, nil
+}
+
+// Encrypt 使用当前的key加密
+func (e *Encryptor) Encrypt(data []byte) ([]byte, error) {
+	aead := e.aeads[e.current]
+	headerLen := 2 + len(e.current)
+	ret := make([]byte, headerLen+aead.NonceSize(), headerLen+aead.NonceSize()+len(data)+aead.Overhead())
+	ret[0] = encryptMagic
+	ret[1] = byte(len(e.current))
+	copy(ret[2:], e.current)
+
+	nonce := ret[headerLen:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(ret, nonce, data, ret[:headerLen]), nil
+}
+
+// Decrypt 根据数据中的key ID解密，不是加密之后的数据时返回errors.ErrDecode
+func (e *Encryptor) Decrypt(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != encryptMagic {
+		return nil, errors.NewDecodeError("", fmt.Errorf("not encrypted"))
+	}
+
+	headerLen := 2 + int(data[1])
+	if len(data) < headerLen {
+		return nil, errors.NewDecodeError("", fmt.Errorf("invalid encrypted data"))
+	}
+	id := string(data[2:headerLen])
+	aead, ok := e.aeads[id]
+	if !ok {
+		return nil, errors.NewDecodeError("", fmt.Errorf("unknown encryption key %q", id))
+	}
+	if len(data) < headerLen+aead.NonceSize() {
+		return nil, errors.NewDecodeError("", fmt.Errorf("invalid encrypted data"))
+	}
+
+	nonce := data[headerLen : headerLen+aead.NonceSize()]
+	ret, err := aead.Open(nil, nonce, data[headerLen+aead.NonceSize():], data[:headerLen])
+	if err != nil {
+		return nil, errors.NewDecodeError("", err)
+	}
+	return ret, nil
+}
+
+// EncryptionOption WithEncryption的可选参数
+type EncryptionOption func(*encryption)
+
+// AllowPlaintext 读取时接受没有加密的数据，用于开启加密之前写入的明文数据的迁移，旧数据过期之后应该去掉
+func AllowPlaintext() EncryptionOption {
+	return func(e *encryption) {
+		e.allowPlaintext = true
+	}
+}
+
+type encryption struct {
+	encryptor      *Encryptor
+	allowPlaintext bool
+}
+
+// 解密缓存中的数据，没有加密的数据只有在allowPlaintext时原样返回，否则返回errors.ErrDecode
+func (e *encryption) decrypt(data interface{}) (interface{}, error) {
+	byteData, ok := toBytes(data)
+	if ok && len(byteData) > 0 && byteData[0] == encryptMagic {
+		return e.encryptor.Decrypt(byteData)
+	}
+	if e.allowPlaintext {
+		return data, nil
+	}
+	return nil, errors.NewDecodeError("", fmt.Errorf("not encrypted"))
+}
@@ -0,0 +1,115 @@
+package go_cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	stdErrors "errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEncryptor(t *testing.T) {
+	ast := assert.New(t)
+
+	_, err := NewEncryptor(EncryptionKey{ID: "k1", Key: []byte("short")})
+	ast.True(stdErrors.Is(err, errors.ErrInvalidOption))
+
+	_, err = NewEncryptor(EncryptionKey{Key: bytes.Repeat([]byte("a"), 16)})
+	ast.True(stdErrors.Is(err, errors.ErrInvalidOption))
+
+	_, err = NewEncryptor(EncryptionKey{ID: "k1", Key: bytes.Repeat([]byte("a"), 16)}, EncryptionKey{ID: "k1", Key: bytes.Repeat([]byte("b"), 32)})
+	ast.True(stdErrors.Is(err, errors.ErrInvalidOption))
+
+	e, err := NewEncryptor(EncryptionKey{ID: "k1", Key: bytes.Repeat([]byte("a"), 32)})
+	ast.Nil(err)
+	data, err := e.Encrypt([]byte("secret"))
+	ast.Nil(err)
+	ast.Equal([]byte{encryptMagic, 2, 'k', '1'}, data[:4])
+	ast.False(bytes.Contains(data, []byte("secret")))
+
+	plain, err := e.Decrypt(data)
+	ast.Nil(err)
+	ast.Equal([]byte("secret"), plain)
+
+	// 被修改过的数据
+	data[len(data)-1] ^= 0xff
+	_, err = e.Decrypt(data)
+	ast.True(stdErrors.Is(err, errors.ErrDecode))
+}
+
+func TestBridgeEncryption(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	k1 := EncryptionKey{ID: "k1", Key: bytes.Repeat([]byte("a"), 32)}
+	k2 := EncryptionKey{ID: "k2", Key: bytes.Repeat([]byte("b"), 32)}
+	e1, err := NewEncryptor(k1)
+	ast.Nil(err)
+	e2, err := NewEncryptor(k2, k1)
+	ast.Nil(err)
+
+	cache := lru.NewLRU(10)
+	fetches := 0
+	fetcher := func() (interface{}, time.Duration, error) {
+		fetches++
+		return "token-123", time.Minute, nil
+	}
+
+	// 开启加密之前写入的明文
+	ast.Nil(cache.Set(ctx, "plain", []byte("old"), time.Minute))
+
+	// 默认不接受明文
+	b0 := MustNewBridge(WithCache(cache), WithEncryption(e1))
+	_, err = b0.FetchWithString(ctx, "plain", fetcher)
+	ast.True(stdErrors.Is(err, errors.ErrDecode))
+
+	b1 := MustNewBridge(WithCache(cache), WithEncryption(e1, AllowPlaintext()))
+	str, err := b1.FetchWithString(ctx, "plain", fetcher)
+	ast.Nil(err)
+	ast.Equal("old", str)
+
+	str, err = b1.FetchWithString(ctx, "token", fetcher)
+	ast.Nil(err)
+	ast.Equal("token-123", str)
+	data, err := cache.Get(ctx, "token")
+	ast.Nil(err)
+	ast.False(bytes.Contains(data.([]byte), []byte("token-123")))
+
+	// 轮换key之后仍然可以读取旧key加密的数据，写入时使用新的key
+	b2 := MustNewBridge(WithCache(cache), WithEncryption(e2), WithCompression(Gzip(gzip.BestSpeed), 0))
+	str, err = b2.FetchWithString(ctx, "token", fetcher)
+	ast.Nil(err)
+	ast.Equal("token-123", str)
+	ast.Equal(1, fetches)
+
+	large := strings.Repeat("secret", 100)
+	str, err = b2.FetchWithString(ctx, "large", func() (interface{}, time.Duration, error) {
+		return large, time.Minute, nil
+	})
+	ast.Nil(err)
+	ast.Equal(large, str)
+	data, err = cache.Get(ctx, "large")
+	ast.Nil(err)
+	it := newItem(data)
+	ast.Equal(compressGzip, it.compressor)
+	ast.Equal([]byte{encryptMagic, 2, 'k', '2'}, it.payload.([]byte)[:4])
+
+	str, err = b2.FetchWithString(ctx, "large", fetcher)
+	ast.Nil(err)
+	ast.Equal(large, str)
+
+	// 没有k2的Bridge不能读取
+	_, err = b1.FetchWithString(ctx, "large", fetcher)
+	ast.True(stdErrors.Is(err, errors.ErrDecode))
+
+	// 批量获取时不存在的key为nil
+	values, err := b2.FetchWithKeys(ctx, "token", "missing", "large")
+	ast.Nil(err)
+	ast.Equal([]interface{}{[]byte("token-123"), nil, []byte(large)}, values)
+}
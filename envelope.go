@@ -0,0 +1,65 @@
+package go_cache
+
+import (
+	"time"
+)
+
+/**
+ * 缓存数据的元数据(envelope)
+ * 开启WithEnvelope之后，fetch写入的数据总是带有元数据：格式版本、codec、写入时间、过期时间(soft TTL)和fetcher返回的类型，
+ * 可以通过ParseEnvelope查看redis中的数据是如何写入的；没有元数据的旧数据仍然可以正常读取
+ */
+
+// Envelope 缓存数据中附带的元数据，没有记录的字段为零值
+type Envelope struct {
+	FormatVersion int
+	Codec         string
+	CreatedAt     time.Time
+	ExpireAt      time.Time // fetcher返回的过期时间，之后的数据可能还会保留一段时间(例如stale-while-revalidate)
+	TypeName      string
+	SchemaVersion uint64 // RegisterSchema注册的版本，codec不是json、protobuf，没有版本号或者数据被压缩、加密时为0
+	NotFound      bool   // 缓存的"不存在"
+	Compressor    byte   // 压缩算法的ID，没有压缩时为0
+	Payload       []byte // 编码(以及压缩、加密)之后的数据
+}
+
+// SoftTTL fetcher返回的过期时间，不知道写入时间或者不过期时为0
+func (e *Envelope) SoftTTL() time.Duration {
+	if e.CreatedAt.IsZero() || e.ExpireAt.IsZero() {
+		return 0
+	}
+	return e.ExpireAt.Sub(e.CreatedAt)
+}
+
+// ParseEnvelope 解析缓存中的数据，没有元数据(原始的json、protobuf等)时返回false
+func ParseEnvelope(data interface{}) (*Envelope, bool) {
+	it := newItem(data)
+	if it.version == 0 {
+		return nil, false
+	}
+
+	payload, _ := toBytes(it.payload)
+	e := &Envelope{
+		FormatVersion: int(it.version),
+		Codec:         it.codec,
+		TypeName:      it.typeName,
+		NotFound:      it.notFound,
+		Compressor:    it.compressor,
+		Payload:       payload,
+	}
+	if writesSchema(it.codec) && it.compressor == compressNone {
+		e.SchemaVersion, _ = splitSchema(payload)
+	}
+	if it.createdAt > 0 {
+		e.CreatedAt = time.Unix(0, it.createdAt)
+	}
+	if it.expireAt > 0 {
+		e.ExpireAt = time.Unix(0, it.expireAt)
+	}
+	return e, true
+}
+
+// 直接使用Decoder解码缓存中的数据时去掉元数据，fetch中已经去掉元数据的payload不受影响
+func payloadOf(data interface{}) interface{} {
+	return newItem(data).payload
+}
@@ -0,0 +1,105 @@
+package go_cache
+
+import (
+	"context"
+	stdErrors "errors"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBridgeEnvelope(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	cache := lru.NewLRU(10)
+	bridge := MustNewBridge(WithCache(cache), WithEnvelope())
+
+	fetches := 0
+	fetcher := func() (interface{}, time.Duration, error) {
+		fetches++
+		return &TempModel{Name: "peter", Age: 23}, time.Minute, nil
+	}
+	for i := 0; i < 2; i++ {
+		value, err := bridge.FetchWithJson(ctx, "user", fetcher, &TempModel{})
+		ast.Nil(err)
+		ast.Equal("peter", value.(*TempModel).Name)
+	}
+	ast.Equal(1, fetches)
+
+	data, err := cache.Get(ctx, "user")
+	ast.Nil(err)
+	e, ok := ParseEnvelope(data)
+	ast.True(ok)
+	ast.Equal(1, e.FormatVersion)
+	ast.Equal(CodecJson, e.Codec)
+	ast.Equal("*go_cache.TempModel", e.TypeName)
+	ast.WithinDuration(time.Now(), e.CreatedAt, time.Second)
+	ast.Equal(time.Minute, e.SoftTTL())
+	ast.False(e.NotFound)
+	ast.JSONEq(`{"name":"peter","age":23,"id":0}`, string(e.Payload))
+
+	// Decoder可以直接解码带有元数据的数据
+	value, err := JsonDecode(&TempModel{})(data)
+	ast.Nil(err)
+	ast.Equal("peter", value.(*TempModel).Name)
+
+	// 不过期的数据也带有元数据
+	_, err = bridge.FetchWithCodec(ctx, "forever", func() (interface{}, time.Duration, error) {
+		return &TempModel{Name: "tom"}, 0, nil
+	}, gobModelCodec{}, &TempModel{})
+	ast.Nil(err)
+	data, err = cache.Get(ctx, "forever")
+	ast.Nil(err)
+	e, ok = ParseEnvelope(data)
+	ast.True(ok)
+	ast.Equal(CodecGob, e.Codec)
+	ast.True(e.ExpireAt.IsZero())
+	ast.Equal(time.Duration(0), e.SoftTTL())
+
+	// 缓存的"不存在"
+	_, err = bridge.FetchWithString(ctx, "missing", func() (interface{}, time.Duration, error) {
+		return nil, time.Minute, errors.ErrNotFound
+	})
+	ast.True(stdErrors.Is(err, errors.ErrNotFound))
+	data, err = cache.Get(ctx, "missing")
+	ast.Nil(err)
+	e, ok = ParseEnvelope(data)
+	ast.True(ok)
+	ast.True(e.NotFound)
+	ast.Equal(CodecString, e.Codec)
+}
+
+func TestBridgeEnvelope_Legacy(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	cache := lru.NewLRU(10)
+	bridge := MustNewBridge(WithCache(cache), WithEnvelope())
+
+	legacy := []byte(`{"name":"peter","age":23}`)
+	_, ok := ParseEnvelope(legacy)
+	ast.False(ok)
+
+	ast.Nil(cache.Set(ctx, "legacy", legacy, time.Minute))
+	value, err := bridge.FetchWithJson(ctx, "legacy", func() (interface{}, time.Duration, error) {
+		return nil, 0, assert.AnError
+	}, &TempModel{})
+	ast.Nil(err)
+	ast.Equal("peter", value.(*TempModel).Name)
+
+	// 泛型版本记录codec的名称
+	_, err = Fetch(ctx, bridge, "typed", func(ctx context.Context) (string, time.Duration, error) {
+		return "abc", time.Minute, nil
+	}, StringCodec())
+	ast.Nil(err)
+	data, err := cache.Get(ctx, "typed")
+	ast.Nil(err)
+	e, ok := ParseEnvelope(data)
+	ast.True(ok)
+	ast.Equal(CodecString, e.Codec)
+	ast.Equal("string", e.TypeName)
+}
@@ -1,9 +1,147 @@
 package errors
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+)
 
 var (
 	ErrEmptyCache        = errors.New("empty value")
 	ErrInvalidValue      = errors.New("invalid value")
 	ErrInvalidCacheValue = errors.New("value from cache should be []byte")
+	ErrInvalidOption     = errors.New("invalid option")
+	ErrNotSupported      = errors.New("not supported")
+	// fetcher返回ErrNotFound(可以被包装)时，会按照fetcher返回的过期时间缓存"不存在"，期间再次获取时直接返回ErrNotFound
+	ErrNotFound = errors.New("not found")
+	// 返回的是过期的旧数据，通过errors.Is(err, ErrStale)判断
+	ErrStale = errors.New("stale value")
+	// 缓存数据的schema版本和model注册的版本不一致，并且不能升级，fetch会当作缓存中不存在重新获取
+	ErrSchemaMismatch = errors.New("schema version mismatch")
+)
+
+// StaleError 刷新数据失败时返回了旧数据，Err为刷新数据时的错误
+type StaleError struct {
+	Err error
+}
+
+func (e *StaleError) Error() string {
+	return ErrStale.Error() + ": " + e.Err.Error()
+}
+
+func (e *StaleError) Unwrap() error {
+	return e.Err
+}
+
+func (e *StaleError) Is(target error) bool {
+	return target == ErrStale
+}
+
+// 错误的类型，通过errors.Is(err, ErrDecode)判断
+var (
+	ErrDecode  = errors.New("decode error")
+	ErrEncode  = errors.New("encode error")
+	ErrBackend = errors.New("backend error")
+	ErrFetcher = errors.New("fetcher error")
+	ErrTimeout = errors.New("timeout")
 )
+
+// Error 包装了具体的错误，带上出错的key和缓存后端的名称，通过errors.As获取
+type Error struct {
+	Kind    error  // ErrDecode、ErrEncode、ErrBackend、ErrFetcher、ErrTimeout
+	Backend string // 缓存后端的名称，例如redis
+	Key     string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Kind.Error())
+	if e.Backend != "" {
+		b.WriteString(" backend=")
+		b.WriteString(e.Backend)
+	}
+	if e.Key != "" {
+		b.WriteString(" key=")
+		b.WriteString(e.Key)
+	}
+	if e.Err != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Wrap 把err包装成kind类型的Error；err本身已经是同类型的Error时只补充缺少的key和backend
+func Wrap(kind error, backend, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if e, ok := err.(*Error); ok && e.Kind == kind {
+		if (e.Backend != "" || backend == "") && (e.Key != "" || key == "") {
+			return e
+		}
+
+		// 错误可能被多个调用方共享，不修改原来的错误
+		ret := *e
+		if ret.Backend == "" {
+			ret.Backend = backend
+		}
+		if ret.Key == "" {
+			ret.Key = key
+		}
+		return &ret
+	}
+
+	return &Error{
+		Kind:    kind,
+		Backend: backend,
+		Key:     key,
+		Err:     err,
+	}
+}
+
+func NewDecodeError(key string, err error) error {
+	return Wrap(ErrDecode, "", key, err)
+}
+
+func NewEncodeError(key string, err error) error {
+	return Wrap(ErrEncode, "", key, err)
+}
+
+func NewFetcherError(key string, err error) error {
+	return Wrap(ErrFetcher, "", key, err)
+}
+
+func NewTimeoutError(key string, err error) error {
+	return Wrap(ErrTimeout, "", key, err)
+}
+
+// NewBackendError 缓存后端的错误，超时或者ctx被取消时为ErrTimeout
+func NewBackendError(backend, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) {
+		return Wrap(ErrTimeout, backend, key, err)
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return Wrap(e.Kind, backend, key, err)
+	}
+	return Wrap(ErrBackend, backend, key, err)
+}
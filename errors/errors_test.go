@@ -0,0 +1,47 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	ast := assert.New(t)
+
+	cause := fmt.Errorf("connection refused")
+	err := NewBackendError("redis", "key", cause)
+	ast.True(errors.Is(err, ErrBackend))
+	ast.True(errors.Is(err, cause))
+	ast.False(errors.Is(err, ErrTimeout))
+	ast.Equal("backend error backend=redis key=key: connection refused", err.Error())
+
+	var e *Error
+	ast.True(errors.As(err, &e))
+	ast.Equal("redis", e.Backend)
+	ast.Equal("key", e.Key)
+
+	// 超时
+	err = NewBackendError("redis", "key", context.DeadlineExceeded)
+	ast.True(errors.Is(err, ErrTimeout))
+	ast.True(errors.Is(err, context.DeadlineExceeded))
+
+	// 补充key，不会重复包装
+	err = NewDecodeError("", cause)
+	wrapped := NewDecodeError("key", err)
+	ast.True(errors.As(wrapped, &e))
+	ast.Equal("key", e.Key)
+	ast.Equal(cause, e.Err)
+	ast.Equal("", err.(*Error).Key)
+
+	// 保留后端返回的错误类型
+	err = NewBackendError("", "key", NewBackendError("redis", "", context.Canceled))
+	ast.True(errors.Is(err, ErrTimeout))
+	ast.True(errors.As(err, &e))
+	ast.Equal("redis", e.Backend)
+
+	ast.Nil(Wrap(ErrFetcher, "", "key", nil))
+}
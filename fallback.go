@@ -0,0 +1,86 @@
+package go_cache
+
+import (
+	"context"
+)
+
+/**
+ * 缓存后端不可用时的降级
+ * 1、读缓存失败时把错误交给回调，然后直接调用fetcher返回数据(不写缓存)，缓存后端的故障不会变成整个服务的故障
+ * 2、写缓存失败时同样会交给回调
+ * 3、可以限制同时降级调用fetcher的数量，超过限制时直接返回缓存后端的错误，避免大量请求打到数据库
+ */
+
+// BackendErrorHandler 缓存后端读写失败时的回调，err是包装之后的*errors.Error(ErrBackend或者ErrTimeout)
+type BackendErrorHandler func(ctx context.Context, key string, err error)
+
+type fallback struct {
+	onError BackendErrorHandler
+	limiter chan struct{} // nil表示不限制并发
+}
+
+func newFallback(onError BackendErrorHandler, maxConcurrency int) *fallback {
+	f := &fallback{onError: onError}
+	if maxConcurrency > 0 {
+		f.limiter = make(chan struct{}, maxConcurrency)
+	}
+	return f
+}
+
+// 获取降级调用fetcher的名额，拿不到时不等待
+func (f *fallback) acquire() bool {
+	if f.limiter == nil {
+		return true
+	}
+	select {
+	case f.limiter <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *fallback) release() {
+	if f.limiter != nil {
+		<-f.limiter
+	}
+}
+
+// 缓存后端读写失败，没有开启降级时什么都不做
+func (c fetchConfig) reportBackendError(ctx context.Context, key string, err error) {
+	if c.fallback != nil && c.fallback.onError != nil {
+		c.fallback.onError(ctx, key, err)
+	}
+}
+
+// 读缓存失败之后是否可以降级调用fetcher
+func (c fetchConfig) canFallback(ctx context.Context) bool {
+	return c.fallback != nil && c.policy.canFetch() && ctx.Err() == nil
+}
+
+// 读缓存失败时直接调用fetcher，获取到的数据不写入缓存
+func fetchFallback(
+	ctx context.Context,
+	cache Cache,
+	key string,
+	groupKey string,
+	fetcher ContextFetcher,
+	e encoder,
+	cfg fetchConfig,
+	backendErr error) (interface{}, error) {
+
+	cfg.reportBackendError(ctx, key, backendErr)
+	if !cfg.canFallback(ctx) {
+		return nil, backendErr
+	}
+
+	// 缓存后端已经不可用，不再尝试写入
+	cfg.policy = PolicyBypass
+	return cfg.do(ctx, namespaceOf(cache), key, groupKey+"|fallback", func(ctx context.Context) (interface{}, error) {
+		if !cfg.fallback.acquire() {
+			return nil, backendErr
+		}
+		defer cfg.fallback.release()
+		return fetchAndSet(ctx, cache, key, fetcher, e, cfg)
+	})
+}
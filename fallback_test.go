@@ -0,0 +1,138 @@
+package go_cache
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var errBackendDown = fmt.Errorf("connection refused")
+
+// 模拟不可用的缓存后端
+type brokenCache struct {
+	*lru.LRU
+}
+
+func (c *brokenCache) Get(ctx context.Context, key string) (interface{}, error) {
+	return nil, errBackendDown
+}
+
+func (c *brokenCache) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	return nil, errBackendDown
+}
+
+func (c *brokenCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return errBackendDown
+}
+
+func (c *brokenCache) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	return errBackendDown
+}
+
+func TestFetchFallback(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	fetchFunc := func() (interface{}, time.Duration, error) {
+		return "db", time.Second, nil
+	}
+
+	// 没有开启降级时返回缓存后端的错误
+	bridge := MustNewBridge(WithCache(&brokenCache{LRU: lru.NewLRU(10)}))
+	_, err := bridge.FetchWithString(ctx, "fallback-key", fetchFunc)
+	ast.True(stdErrors.Is(err, errors.ErrBackend))
+	ast.True(stdErrors.Is(err, errBackendDown))
+
+	var reported int32
+	bridge = MustNewBridge(WithCache(&brokenCache{LRU: lru.NewLRU(10)}), WithFallback(func(ctx context.Context, key string, err error) {
+		atomic.AddInt32(&reported, 1)
+		ast.Equal("fallback-key", key)
+		ast.True(stdErrors.Is(err, errors.ErrBackend))
+	}, 0))
+	ret, err := bridge.FetchWithString(ctx, "fallback-key", fetchFunc)
+	ast.Nil(err)
+	ast.Equal("db", ret)
+	ast.EqualValues(1, atomic.LoadInt32(&reported))
+
+	// 只读缓存时不会降级
+	_, err = bridge.FetchWithString(WithPolicy(ctx, PolicyCacheOnly), "fallback-key", fetchFunc)
+	ast.True(stdErrors.Is(err, errors.ErrBackend))
+
+	// 批量获取
+	values, err := FetchMulti(ctx, bridge, []string{"fallback-key"}, func(ctx context.Context, keys []string) (map[string]string, time.Duration, error) {
+		return map[string]string{"fallback-key": "db"}, time.Second, nil
+	}, StringCodec())
+	ast.Nil(err)
+	ast.Equal("db", values["fallback-key"])
+}
+
+func TestFetchFallbackWriteError(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	var reported int32
+	cache := &writeBrokenCache{brokenCache{LRU: lru.NewLRU(10)}}
+	bridge := MustNewBridge(WithCache(cache), WithFallback(func(ctx context.Context, key string, err error) {
+		atomic.AddInt32(&reported, 1)
+	}, 0))
+
+	ret, err := bridge.FetchWithString(ctx, "fallback-write-key", func() (interface{}, time.Duration, error) {
+		return "db", time.Second, nil
+	})
+	ast.Nil(err)
+	ast.Equal("db", ret)
+	ast.EqualValues(1, atomic.LoadInt32(&reported))
+}
+
+// 只有写缓存失败
+type writeBrokenCache struct {
+	brokenCache
+}
+
+func (c *writeBrokenCache) Get(ctx context.Context, key string) (interface{}, error) {
+	return c.LRU.Get(ctx, key)
+}
+
+func TestFetchFallbackConcurrency(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	bridge := MustNewBridge(WithCache(&brokenCache{LRU: lru.NewLRU(10)}), WithFallback(nil, 1))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ret, err := bridge.FetchWithString(ctx, "fallback-key1", func() (interface{}, time.Duration, error) {
+			close(started)
+			<-release
+			return "db", time.Second, nil
+		})
+		ast.Nil(err)
+		ast.Equal("db", ret)
+	}()
+
+	// 超过并发限制时直接返回缓存后端的错误
+	<-started
+	_, err := bridge.FetchWithString(ctx, "fallback-key2", func() (interface{}, time.Duration, error) {
+		return "db", time.Second, nil
+	})
+	ast.True(stdErrors.Is(err, errors.ErrBackend))
+
+	close(release)
+	wg.Wait()
+	ret, err := bridge.FetchWithString(ctx, "fallback-key2", func() (interface{}, time.Duration, error) {
+		return "db", time.Second, nil
+	})
+	ast.Nil(err)
+	ast.Equal("db", ret)
+}
@@ -0,0 +1,139 @@
+package go_cache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/liyanbing/go-cache/tools"
+)
+
+/**
+ * 和singleflight一样合并相同key的并发调用，不同的是：
+ * 1、调用方的ctx被取消之后会立即返回ctx.Err()，共享的调用会继续执行
+ * 2、所有的调用方都离开之后，传给共享调用的ctx会被取消
+ * 每个Bridge有自己的Group，key会带上namespace，不同的Bridge或者namespace之间不会相互合并
+ */
+
+// Group 合并相同key的并发调用，可以通过WithGroup替换Bridge使用的Group
+type Group interface {
+	Do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error)
+}
+
+func NewGroup() Group {
+	return &group{}
+}
+
+// 每个Bridge独立的合并状态
+type flight struct {
+	group      Group
+	refreshing sync.Map // 正在后台刷新的key
+	shared     bool     // 多个Cache共用，key需要带上Cache的地址
+}
+
+func newFlight(g Group) *flight {
+	if g == nil {
+		g = NewGroup()
+	}
+	return &flight{group: g}
+}
+
+/**
+ * 直接把Cache传给FetchWithJson等包级函数时使用的flight，不同的Cache通过地址区分
+ * 调用进行中时Cache被调用引用，地址不会被其他Cache复用；调用结束之后key会从group中删除，不会一直占用内存
+ * 不是指针类型的Cache无法区分，每次调用使用新的flight，不会合并
+ * 需要合并调用或者替换Group时应该使用Bridge
+ */
+var sharedFlight = &flight{group: NewGroup(), shared: true}
+
+func flightOf(cache Cache) *flight {
+	if _, ok := cacheAddr(cache); ok {
+		return sharedFlight
+	}
+	return newFlight(nil)
+}
+
+// 指针类型Cache的地址
+func cacheAddr(cache Cache) (uintptr, bool) {
+	v := reflect.ValueOf(cache)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Chan:
+		return v.Pointer(), true
+	}
+	return 0, false
+}
+
+// 合并调用时使用的key，带上namespace，共用的flight还需要带上Cache的地址
+func (f *flight) key(cache Cache, key string) string {
+	if namespace := namespaceOf(cache); namespace != "" {
+		key = fmt.Sprintf("%v:%v", namespace, key)
+	}
+	if f.shared {
+		addr, _ := cacheAddr(cache)
+		key = fmt.Sprintf("%x|%v", addr, key)
+	}
+	return key
+}
+
+type call struct {
+	done    chan struct{}
+	value   interface{}
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+func (g *group) Do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+
+	c, ok := g.calls[key]
+	if !ok {
+		// 共享调用的ctx保留调用方ctx中的值，但是不会因为调用方的ctx取消而取消
+		fnCtx, cancel := context.WithCancel(tools.WithoutCancel(ctx))
+		c = &call{
+			done:   make(chan struct{}),
+			cancel: cancel,
+		}
+		g.calls[key] = c
+
+		go func() {
+			c.value, c.err = fn(fnCtx)
+			cancel()
+
+			g.mu.Lock()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			close(c.done)
+		}()
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.value, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			// 没有调用方在等待了，取消共享调用，之后的调用会重新发起
+			c.cancel()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
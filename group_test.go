@@ -0,0 +1,174 @@
+package go_cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup_Do(t *testing.T) {
+	ast := assert.New(t)
+	g := &group{}
+
+	cnt := int32(0)
+	fn := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&cnt, 1)
+		time.Sleep(time.Millisecond * 50)
+		return "value", nil
+	}
+
+	wait := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			value, err := g.Do(context.Background(), "key", fn)
+			ast.Nil(err)
+			ast.Equal("value", value)
+		}()
+	}
+	wait.Wait()
+	ast.EqualValues(1, atomic.LoadInt32(&cnt))
+}
+
+func TestGroup_DoCancel(t *testing.T) {
+	ast := assert.New(t)
+	g := &group{}
+
+	// 一个调用方取消之后立即返回，共享调用继续执行
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		value, err := g.Do(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+			time.Sleep(time.Millisecond * 50)
+			return "value", ctx.Err()
+		})
+		ast.Nil(err)
+		ast.Equal("value", value)
+	}()
+
+	time.Sleep(time.Millisecond)
+	start := time.Now()
+	_, err := g.Do(ctx, "key", nil)
+	ast.Equal(context.DeadlineExceeded, err)
+	ast.True(time.Since(start) < time.Millisecond*40)
+	<-done
+
+	// 所有调用方都取消之后，共享调用的ctx被取消
+	ctx2, cancel2 := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel2()
+
+	canceled := make(chan struct{})
+	_, err = g.Do(ctx2, "key", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	})
+	ast.Equal(context.DeadlineExceeded, err)
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("shared call was not canceled")
+	}
+}
+
+type countGroup struct {
+	Group
+	keys sync.Map
+}
+
+func (g *countGroup) Do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	cnt, _ := g.keys.LoadOrStore(key, new(int32))
+	atomic.AddInt32(cnt.(*int32), 1)
+	return g.Group.Do(ctx, key, fn)
+}
+
+func TestGroup_Namespace(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	g := &countGroup{Group: NewGroup()}
+	bridge1 := MustNewBridge(WithLRU(10), WithGroup(g))
+	bridge1.SetNamespace("user")
+	bridge2 := MustNewBridge(WithLRU(10), WithGroup(g))
+	bridge2.SetNamespace("order")
+
+	// 不同namespace下相同的key不会合并，也不会拿到对方的数据
+	wait := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wait.Add(2)
+		go func() {
+			defer wait.Done()
+			ret, err := bridge1.FetchWithString(ctx, "1", func() (interface{}, time.Duration, error) {
+				time.Sleep(time.Millisecond * 20)
+				return "user", time.Minute, nil
+			})
+			ast.Nil(err)
+			ast.Equal("user", ret)
+		}()
+		go func() {
+			defer wait.Done()
+			ret, err := bridge2.FetchWithNumber(ctx, "1", func() (interface{}, time.Duration, error) {
+				time.Sleep(time.Millisecond * 20)
+				return 1024, time.Minute, nil
+			})
+			ast.Nil(err)
+			ast.EqualValues(1024, ret)
+		}()
+	}
+	wait.Wait()
+
+	_, ok := g.keys.Load("user:1")
+	ast.True(ok)
+	_, ok = g.keys.Load("order:1")
+	ast.True(ok)
+}
+
+func TestGroup_SharedFlight(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	// 直接使用Cache时，不同的Cache相同的key不会合并
+	cache1, cache2 := lru.NewLRU(10), lru.NewLRU(10)
+	wait := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wait.Add(2)
+		go func() {
+			defer wait.Done()
+			ret, err := FetchWithString(ctx, cache1, "1", func() (interface{}, time.Duration, error) {
+				time.Sleep(time.Millisecond * 20)
+				return "cache1", time.Minute, nil
+			})
+			ast.Nil(err)
+			ast.Equal("cache1", ret)
+		}()
+		go func() {
+			defer wait.Done()
+			ret, err := FetchWithString(ctx, cache2, "1", func() (interface{}, time.Duration, error) {
+				time.Sleep(time.Millisecond * 20)
+				return "cache2", time.Minute, nil
+			})
+			ast.Nil(err)
+			ast.Equal("cache2", ret)
+		}()
+	}
+	wait.Wait()
+
+	// 调用结束之后不会保留Cache的状态
+	g := sharedFlight.group.(*group)
+	g.mu.Lock()
+	ast.Len(g.calls, 0)
+	g.mu.Unlock()
+
+	// 不是指针类型的Cache不共用flight
+	ast.True(flightOf(basicCache{cache1}) != flightOf(basicCache{cache1}))
+	ast.True(flightOf(cache1) == flightOf(cache2))
+}
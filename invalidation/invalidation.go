@@ -0,0 +1,263 @@
+package invalidation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	stdJson "encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/liyanbing/go-cache/logger"
+	"github.com/liyanbing/go-cache/tools"
+)
+
+/**
+ * 通过redis的pub/sub在多个进程之间同步进程内缓存(lru、memory)的删除
+ * 1、Bridge的Set、MSet、Remove成功之后把namespace和key发布到channel
+ * 2、其他进程收到之后从进程内的缓存中删除对应的key，自己发布的消息会被忽略
+ * 3、和redis断开连接期间可能丢失消息，重新订阅之后清空所有进程内的缓存
+ */
+
+const (
+	defaultChannel      = "go-cache:invalidation"
+	defaultPingInterval = 5 * time.Second
+	maxBackoff          = time.Second
+)
+
+// Local 进程内的缓存，例如lru、memory
+type Local interface {
+	Namespace() string
+	Remove(ctx context.Context, key ...string) error
+	Flush()
+}
+
+// Message channel中的消息，Flush为true时清空namespace下所有的数据
+type Message struct {
+	Source    string   `json:"source"`
+	Namespace string   `json:"namespace"`
+	Keys      []string `json:"keys,omitempty"`
+	Flush     bool     `json:"flush,omitempty"`
+}
+
+type Option func(*Bus)
+
+// WithChannel 发布和订阅的channel，默认为go-cache:invalidation
+func WithChannel(channel string) Option {
+	return func(b *Bus) {
+		b.channel = channel
+	}
+}
+
+// WithPingInterval 超过interval没有收到消息时ping一次，检查连接是否正常
+func WithPingInterval(interval time.Duration) Option {
+	return func(b *Bus) {
+		b.pingInterval = interval
+	}
+}
+
+func WithLogger(l logger.Logger) Option {
+	return func(b *Bus) {
+		b.logger = l
+	}
+}
+
+// WithFlushHandler 断开连接之后重新订阅，清空了所有进程内的缓存时调用fn
+func WithFlushHandler(fn func()) Option {
+	return func(b *Bus) {
+		b.onFlush = fn
+	}
+}
+
+type Bus struct {
+	cli          redis.UniversalClient
+	channel      string
+	id           string
+	pingInterval time.Duration
+	logger       logger.Logger
+	onFlush      func()
+
+	mu     sync.RWMutex
+	locals []Local
+
+	pubsub *redis.PubSub
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewBus(cli redis.UniversalClient, opts ...Option) *Bus {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+
+	b := &Bus{
+		cli:          cli,
+		channel:      defaultChannel,
+		id:           hex.EncodeToString(buf),
+		pingInterval: defaultPingInterval,
+		logger:       logger.Default(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Register 收到消息时从local中删除数据
+func (b *Bus) Register(local Local) {
+	b.mu.Lock()
+	b.locals = append(b.locals, local)
+	b.mu.Unlock()
+}
+
+// Publish 通知其他进程删除namespace下的keys
+func (b *Bus) Publish(ctx context.Context, namespace string, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return b.publish(ctx, &Message{Namespace: namespace, Keys: keys})
+}
+
+// PublishFlush 通知其他进程清空namespace下所有的数据
+func (b *Bus) PublishFlush(ctx context.Context, namespace string) error {
+	return b.publish(ctx, &Message{Namespace: namespace, Flush: true})
+}
+
+func (b *Bus) publish(ctx context.Context, msg *Message) error {
+	msg.Source = b.id
+	data, err := stdJson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return b.cli.Publish(ctx, b.channel, data).Err()
+}
+
+// Start 订阅channel，订阅成功之后在后台接收消息，直到调用Close
+func (b *Bus) Start(ctx context.Context) error {
+	pubsub := b.cli.Subscribe(ctx, b.channel)
+	// 等待订阅成功
+	_, err := pubsub.Receive(ctx)
+	if err != nil {
+		_ = pubsub.Close()
+		return err
+	}
+
+	runCtx, cancel := context.WithCancel(tools.WithoutCancel(ctx))
+	b.pubsub = pubsub
+	b.cancel = cancel
+	b.done = make(chan struct{})
+	go b.run(runCtx)
+	return nil
+}
+
+// Close 取消订阅，停止接收消息
+func (b *Bus) Close() error {
+	if b.pubsub == nil {
+		return nil
+	}
+	b.cancel()
+	err := b.pubsub.Close()
+	<-b.done
+	return err
+}
+
+func (b *Bus) run(ctx context.Context) {
+	defer close(b.done)
+
+	disconnected := false
+	backoff := time.Duration(0)
+	for ctx.Err() == nil {
+		msg, err := b.pubsub.ReceiveTimeout(ctx, b.pingInterval)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			// 一段时间没有收到消息，ping一次检查连接
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				err = b.pubsub.Ping(ctx)
+				if err == nil {
+					continue
+				}
+			}
+
+			if !disconnected {
+				b.logger.Warn("invalidation subscription lost", "channel", b.channel, "err", err)
+			}
+			disconnected = true
+			backoff = nextBackoff(backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		backoff = 0
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			// 重新订阅成功，断开期间的消息已经丢失，只能清空所有进程内的缓存
+			if disconnected && msg.Kind == "subscribe" {
+				disconnected = false
+				b.logger.Info("invalidation subscription restored, flush local caches", "channel", b.channel)
+				b.flushAll()
+			}
+		case *redis.Message:
+			b.handle(ctx, msg.Payload)
+		}
+	}
+}
+
+func nextBackoff(backoff time.Duration) time.Duration {
+	if backoff == 0 {
+		return 10 * time.Millisecond
+	}
+	backoff *= 2
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
+
+func (b *Bus) handle(ctx context.Context, payload string) {
+	var msg Message
+	err := stdJson.Unmarshal([]byte(payload), &msg)
+	if err != nil {
+		b.logger.Warn("invalid invalidation message", "payload", payload, "err", err)
+		return
+	}
+	// 自己发布的消息，进程内的缓存已经是最新的
+	if msg.Source == b.id {
+		return
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, local := range b.locals {
+		if local.Namespace() != msg.Namespace {
+			continue
+		}
+		if msg.Flush {
+			local.Flush()
+			continue
+		}
+		err := local.Remove(ctx, msg.Keys...)
+		if err != nil {
+			b.logger.Warn("invalidate local cache failed", "namespace", msg.Namespace, "keys", msg.Keys, "err", err)
+		}
+	}
+}
+
+func (b *Bus) flushAll() {
+	b.mu.RLock()
+	for _, local := range b.locals {
+		local.Flush()
+	}
+	b.mu.RUnlock()
+
+	if b.onFlush != nil {
+		b.onFlush()
+	}
+}
@@ -0,0 +1,94 @@
+package invalidation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/liyanbing/go-cache/logger"
+	"github.com/stretchr/testify/assert"
+
+	redis "github.com/go-redis/redis/v8"
+)
+
+func newBus(t *testing.T, addr string, opts ...Option) (*Bus, *lru.LRU) {
+	cli := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	t.Cleanup(func() { _ = cli.Close() })
+
+	local := lru.NewLRU(10)
+	local.SetNamespace("ns")
+	bus := NewBus(cli, append([]Option{WithLogger(logger.Nop())}, opts...)...)
+	bus.Register(local)
+	assert.Nil(t, bus.Start(context.Background()))
+	t.Cleanup(func() { _ = bus.Close() })
+	return bus, local
+}
+
+func cached(local *lru.LRU, key string) bool {
+	_, err := local.Get(context.Background(), key)
+	return err != errors.ErrEmptyCache
+}
+
+func TestBus(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	m := miniredis.NewMiniRedis()
+	ast.Nil(m.Start())
+	defer m.Close()
+
+	busA, localA := newBus(t, m.Addr())
+	_, localB := newBus(t, m.Addr())
+	for _, local := range []*lru.LRU{localA, localB} {
+		_ = local.Set(ctx, "k1", "v", 0)
+		_ = local.Set(ctx, "k2", "v", 0)
+	}
+
+	// 其他进程删除，自己发布的消息被忽略
+	ast.Nil(busA.Publish(ctx, "ns", "k1"))
+	ast.Eventually(func() bool { return !cached(localB, "k1") }, time.Second, time.Millisecond*5)
+	ast.True(cached(localB, "k2"))
+	ast.True(cached(localA, "k1"))
+
+	// 其他namespace的消息
+	ast.Nil(busA.Publish(ctx, "other", "k2"))
+	ast.Nil(busA.PublishFlush(ctx, "ns"))
+	ast.Eventually(func() bool { return !cached(localB, "k2") }, time.Second, time.Millisecond*5)
+	ast.True(cached(localA, "k2"))
+}
+
+func TestBusReconnect(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	m := miniredis.NewMiniRedis()
+	ast.Nil(m.Start())
+	defer m.Close()
+
+	flushed := make(chan struct{}, 1)
+	_, local := newBus(t, m.Addr(), WithPingInterval(time.Millisecond*20), WithFlushHandler(func() {
+		flushed <- struct{}{}
+	}))
+	_ = local.Set(ctx, "k1", "v", 0)
+
+	// 断开期间可能丢失消息，重新订阅之后清空进程内的缓存
+	m.Close()
+	time.Sleep(time.Millisecond * 50)
+	ast.Nil(m.Restart())
+
+	select {
+	case <-flushed:
+	case <-time.After(time.Second * 3):
+		t.Fatal("local cache not flushed after reconnect")
+	}
+	ast.False(cached(local, "k1"))
+
+	// 重新订阅之后可以继续接收消息
+	_ = local.Set(ctx, "k2", "v", 0)
+	other, _ := newBus(t, m.Addr())
+	ast.Nil(other.Publish(ctx, "ns", "k2"))
+	ast.Eventually(func() bool { return !cached(local, "k2") }, time.Second, time.Millisecond*5)
+}
@@ -0,0 +1,170 @@
+package go_cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"time"
+)
+
+/**
+ * 需要在缓存数据中附带元数据(例如过期时间)时，写入缓存的数据格式为：
+ * magic(3字节) + version(1字节) + 元数据长度(uvarint) + 元数据 + payload
+ * 元数据由若干个 tag(1字节) + 长度(uvarint) + 数据 组成，不认识的tag会被跳过
+ * 不带元数据的旧数据(原始的json、protobuf等)按原样返回
+ * 开启WithEnvelope时总是附带元数据，并且记录codec、写入时间和fetcher返回的类型，可以通过ParseEnvelope查看
+ */
+
+var itemMagic = []byte{0x00, 'g', 'c'}
+
+const (
+	itemVersion byte = 1
+
+	itemTagExpireAt byte = 1
+	itemTagNotFound byte = 2
+	itemTagDelta    byte = 3
+	itemTagCreated  byte = 4
+	itemTagCodec    byte = 5
+	itemTagType     byte = 6
+	itemTagCompress byte = 7
+)
+
+type item struct {
+	expireAt int64       // fetcher返回的过期时间(unix nano)，0表示不过期；写入缓存的过期时间可能更长(例如stale-while-revalidate)
+	delta    int64       // 调用fetcher花费的时间(ns)
+	payload  interface{} // 编码之后的业务数据
+	notFound bool        // 数据不存在的占位(防止缓存穿透)
+
+	version   byte   // 格式的版本，不带元数据时为0
+	createdAt int64  // 写入的时间(unix nano)
+	codec     string // 编码使用的codec名称，例如json
+	typeName  string // fetcher返回的数据类型，例如*model.User
+
+	compressor byte // payload的压缩算法，compressNone表示没有压缩
+}
+
+// 解析缓存中的数据，非本格式的数据作为payload原样返回
+func newItem(data interface{}) *item {
+	byteData, _ := toBytes(data)
+	if !bytes.HasPrefix(byteData, itemMagic) || len(byteData) <= len(itemMagic) {
+		return &item{payload: data}
+	}
+
+	buf := byteData[len(itemMagic)+1:]
+	headerLen, n := binary.Uvarint(buf)
+	if n <= 0 || uint64(len(buf)-n) < headerLen {
+		return &item{payload: data}
+	}
+
+	it := &item{payload: buf[n+int(headerLen):], version: byteData[len(itemMagic)]}
+	header := buf[n : n+int(headerLen)]
+	for len(header) > 0 {
+		tag := header[0]
+		fieldLen, n := binary.Uvarint(header[1:])
+		if n <= 0 || uint64(len(header)-1-n) < fieldLen {
+			return &item{payload: data}
+		}
+		field := header[1+n : 1+n+int(fieldLen)]
+		header = header[1+n+int(fieldLen):]
+
+		switch tag {
+		case itemTagExpireAt:
+			it.expireAt, _ = binary.Varint(field)
+		case itemTagDelta:
+			it.delta, _ = binary.Varint(field)
+		case itemTagNotFound:
+			it.notFound = true
+		case itemTagCreated:
+			it.createdAt, _ = binary.Varint(field)
+		case itemTagCodec:
+			it.codec = string(field)
+		case itemTagType:
+			it.typeName = string(field)
+		case itemTagCompress:
+			if len(field) == 1 {
+				it.compressor = field[0]
+			}
+		}
+	}
+
+	// 保持和原始数据一样的类型
+	if _, ok := data.(string); ok {
+		it.payload = string(it.payload.([]byte))
+	}
+	return it
+}
+
+func (i *item) marshal() []byte {
+	var header []byte
+	if i.expireAt > 0 {
+		header = appendVarintField(header, itemTagExpireAt, i.expireAt)
+	}
+	if i.delta > 0 {
+		header = appendVarintField(header, itemTagDelta, i.delta)
+	}
+	if i.notFound {
+		header = appendField(header, itemTagNotFound, nil)
+	}
+	if i.createdAt > 0 {
+		header = appendVarintField(header, itemTagCreated, i.createdAt)
+	}
+	if i.codec != "" {
+		header = appendField(header, itemTagCodec, []byte(i.codec))
+	}
+	if i.typeName != "" {
+		header = appendField(header, itemTagType, []byte(i.typeName))
+	}
+	if i.compressor != compressNone {
+		header = appendField(header, itemTagCompress, []byte{i.compressor})
+	}
+
+	payload, _ := toBytes(i.payload)
+	data := make([]byte, 0, len(itemMagic)+1+binary.MaxVarintLen64+len(header)+len(payload))
+	data = append(data, itemMagic...)
+	data = append(data, itemVersion)
+	data = appendUvarint(data, uint64(len(header)))
+	data = append(data, header...)
+	return append(data, payload...)
+}
+
+// 是否已经超过fetcher返回的过期时间
+func (i *item) expired(now time.Time) bool {
+	return i.expireAt > 0 && now.UnixNano() > i.expireAt
+}
+
+// 已经过期了多长时间
+func (i *item) staleFor(now time.Time) time.Duration {
+	return time.Duration(now.UnixNano() - i.expireAt)
+}
+
+// XFetch: 根据调用fetcher花费的时间和剩余的过期时间随机地提前刷新，越接近过期时间、fetcher越慢，提前刷新的概率越大
+// now - delta * beta * ln(rand()) >= expireAt
+func (i *item) expireEarly(now time.Time, beta float64) bool {
+	if i.expireAt <= 0 || i.delta <= 0 || beta <= 0 {
+		return false
+	}
+	gap := float64(i.delta) * beta * math.Log(1-rand.Float64())
+	return float64(now.UnixNano())-gap >= float64(i.expireAt)
+}
+
+func appendVarintField(header []byte, tag byte, value int64) []byte {
+	return appendField(header, tag, appendVarint(nil, value))
+}
+
+// 和go1.19的binary.AppendUvarint一样
+func appendUvarint(buf []byte, x uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], x)]...)
+}
+
+func appendVarint(buf []byte, x int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutVarint(tmp[:], x)]...)
+}
+
+func appendField(header []byte, tag byte, field []byte) []byte {
+	header = append(header, tag)
+	header = appendUvarint(header, uint64(len(field)))
+	return append(header, field...)
+}
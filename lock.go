@@ -0,0 +1,98 @@
+package go_cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/liyanbing/go-cache/tools"
+)
+
+/**
+ * 跨进程的填充锁：缓存不存在时只有拿到锁的进程调用fetcher，其他进程轮询缓存等待数据写入，
+ * 超过锁的过期时间还没有等到数据时自己调用fetcher
+ */
+
+// Locker 由支持分布式锁的Cache实现(例如redis)
+type Locker interface {
+	// Lock 获取key的锁，获取成功时返回用于释放锁的token
+	Lock(ctx context.Context, key string, expiration time.Duration) (token string, ok bool, err error)
+	// Unlock 释放key的锁，token不一致时什么都不做
+	Unlock(ctx context.Context, key string, token string) error
+}
+
+type fillLock struct {
+	expiration   time.Duration // 锁的过期时间，也是其他进程最长的等待时间
+	pollInterval time.Duration // 其他进程轮询缓存的间隔
+}
+
+func fetchWithLock(
+	ctx context.Context,
+	cache Cache,
+	key string,
+	fetcher ContextFetcher,
+	e encoder,
+	d Decoder,
+	cfg fetchConfig) (interface{}, error) {
+
+	if cfg.fillLock == nil || cfg.fillLock.expiration <= 0 || cfg.locker == nil || !cfg.policy.canWrite() {
+		return fetchAndSet(ctx, cache, key, fetcher, e, cfg)
+	}
+
+	token, ok, err := cfg.locker.Lock(ctx, key, cfg.fillLock.expiration)
+	if err != nil {
+		cfg.logger.Warn("lock failed", "key", key, "err", err)
+		return fetchAndSet(ctx, cache, key, fetcher, e, cfg)
+	}
+
+	if ok {
+		defer func() {
+			err := cfg.locker.Unlock(tools.WithoutCancel(ctx), key, token)
+			if err != nil {
+				cfg.logger.Warn("unlock failed", "key", key, "err", err)
+			}
+		}()
+		return fetchAndSet(ctx, cache, key, fetcher, e, cfg)
+	}
+
+	// 其他进程正在调用fetcher，等待数据写入缓存
+	deadline := time.Now().Add(cfg.fillLock.expiration)
+	ticker := time.NewTicker(cfg.fillLock.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, errors.NewTimeoutError(key, ctx.Err())
+		case <-ticker.C:
+		}
+
+		cacheData, err := cache.Get(ctx, key)
+		if err == nil {
+			it := newItem(cacheData)
+			if it.notFound {
+				return nil, errors.ErrNotFound
+			}
+			if !it.expired(time.Now()) {
+				value, err := cfg.decode(d, it)
+				if err == nil {
+					return value, nil
+				}
+				// 新版本的进程写入的数据，不再等待，也不覆盖
+				if isSchemaNewer(err) {
+					return fetchAndSet(ctx, cache, key, fetcher, e, cfg.readOnly())
+				}
+				// 旧版本的数据，继续等待拿到锁的进程写入新的数据
+				if !isSchemaMismatch(err) {
+					err = errors.NewDecodeError(key, err)
+					cfg.observer.OnDecodeError(ctx, namespaceOf(cache), key, err)
+					return nil, err
+				}
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fetchAndSet(ctx, cache, key, fetcher, e, cfg)
+		}
+	}
+}
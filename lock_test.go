@@ -0,0 +1,67 @@
+package go_cache
+
+import (
+	"context"
+	stdErrors "errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// 模拟锁一直被其他进程持有
+type lockedCache struct {
+	*lru.LRU
+	locks int32
+}
+
+func (c *lockedCache) Lock(ctx context.Context, key string, expiration time.Duration) (string, bool, error) {
+	atomic.AddInt32(&c.locks, 1)
+	return "", false, nil
+}
+
+func (c *lockedCache) Unlock(ctx context.Context, key string, token string) error {
+	return nil
+}
+
+func TestFetchWithFillLock(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	cache := &lockedCache{LRU: lru.NewLRU(10)}
+	bridge := MustNewBridge(WithCache(cache), WithFillLock(time.Millisecond*100, time.Millisecond*5))
+
+	cnt := int32(0)
+	fetchFunc := func() (interface{}, time.Duration, error) {
+		atomic.AddInt32(&cnt, 1)
+		return "local", time.Minute, nil
+	}
+
+	// 其他进程写入了数据
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		_ = cache.Set(ctx, "lock-key", []byte("remote"), time.Minute)
+	}()
+	ret, err := bridge.FetchWithString(ctx, "lock-key", fetchFunc)
+	ast.Nil(err)
+	ast.Equal("remote", ret)
+	ast.EqualValues(0, atomic.LoadInt32(&cnt))
+	ast.EqualValues(1, atomic.LoadInt32(&cache.locks))
+
+	// 等待超时之后自己调用fetcher
+	start := time.Now()
+	ret, err = bridge.FetchWithString(ctx, "lock-key2", fetchFunc)
+	ast.Nil(err)
+	ast.Equal("local", ret)
+	ast.EqualValues(1, atomic.LoadInt32(&cnt))
+	ast.True(time.Since(start) >= time.Millisecond*100)
+
+	// 调用方取消时立即返回
+	ctx2, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+	_, err = bridge.FetchWithString(ctx2, "lock-key3", fetchFunc)
+	ast.True(stdErrors.Is(err, errors.ErrTimeout))
+	ast.True(stdErrors.Is(err, context.DeadlineExceeded))
+}
@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger 分级、key/value结构化的日志，keyvals为成对的key和value，例如：
+// logger.Warn("set cache failed", "key", key, "err", err)
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+type Level int8
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+type std struct {
+	logger *log.Logger
+	level  Level
+}
+
+// Std 通过标准库的log输出不低于level的日志，logger为nil时使用log的默认Logger
+func Std(logger *log.Logger, level Level) Logger {
+	return &std{
+		logger: logger,
+		level:  level,
+	}
+}
+
+func (s *std) output(level Level, msg string, keyvals []interface{}) {
+	if level < s.level {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(level.String())
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		b.WriteString(" ")
+		if i+1 < len(keyvals) {
+			fmt.Fprintf(&b, "%v=%v", keyvals[i], keyvals[i+1])
+		} else {
+			fmt.Fprintf(&b, "%v", keyvals[i])
+		}
+	}
+
+	if s.logger == nil {
+		log.Output(3, b.String())
+		return
+	}
+	s.logger.Output(3, b.String())
+}
+
+func (s *std) Debug(msg string, keyvals ...interface{}) {
+	s.output(LevelDebug, msg, keyvals)
+}
+
+func (s *std) Info(msg string, keyvals ...interface{}) {
+	s.output(LevelInfo, msg, keyvals)
+}
+
+func (s *std) Warn(msg string, keyvals ...interface{}) {
+	s.output(LevelWarn, msg, keyvals)
+}
+
+func (s *std) Error(msg string, keyvals ...interface{}) {
+	s.output(LevelError, msg, keyvals)
+}
+
+type nop struct{}
+
+// Nop 不输出任何日志
+func Nop() Logger {
+	return nop{}
+}
+
+func (nop) Debug(msg string, keyvals ...interface{}) {}
+
+func (nop) Info(msg string, keyvals ...interface{}) {}
+
+func (nop) Warn(msg string, keyvals ...interface{}) {}
+
+func (nop) Error(msg string, keyvals ...interface{}) {}
+
+// 默认的日志，和之前一样通过标准库的log输出
+var defaultLogger = Std(nil, LevelInfo)
+
+func Default() Logger {
+	return defaultLogger
+}
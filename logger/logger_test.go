@@ -0,0 +1,19 @@
+package logger
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStd(t *testing.T) {
+	ast := assert.New(t)
+
+	var buf bytes.Buffer
+	l := Std(log.New(&buf, "", 0), LevelWarn)
+	l.Info("ignored", "key", "a")
+	l.Warn("set failed", "key", "a", "err", "timeout", "odd")
+	ast.Equal("WARN set failed key=a err=timeout odd\n", buf.String())
+}
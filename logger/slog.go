@@ -0,0 +1,36 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+type slogger struct {
+	logger *slog.Logger
+}
+
+// Slog 把日志输出到log/slog，logger为nil时使用slog.Default()
+func Slog(logger *slog.Logger) Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &slogger{logger: logger}
+}
+
+func (s *slogger) Debug(msg string, keyvals ...interface{}) {
+	s.logger.Log(context.Background(), slog.LevelDebug, msg, keyvals...)
+}
+
+func (s *slogger) Info(msg string, keyvals ...interface{}) {
+	s.logger.Log(context.Background(), slog.LevelInfo, msg, keyvals...)
+}
+
+func (s *slogger) Warn(msg string, keyvals ...interface{}) {
+	s.logger.Log(context.Background(), slog.LevelWarn, msg, keyvals...)
+}
+
+func (s *slogger) Error(msg string, keyvals ...interface{}) {
+	s.logger.Log(context.Background(), slog.LevelError, msg, keyvals...)
+}
@@ -0,0 +1,28 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlog(t *testing.T) {
+	ast := assert.New(t)
+
+	var buf bytes.Buffer
+	l := Slog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+	l.Debug("ignored")
+	l.Error("set failed", "key", "a")
+	ast.Equal("level=ERROR msg=\"set failed\" key=a\n", buf.String())
+}
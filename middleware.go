@@ -0,0 +1,353 @@
+package go_cache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/liyanbing/go-cache/errors"
+)
+
+/**
+ * Cache的中间件，在不修改Cache实现的情况下增加超时、重试、key前缀等功能
+ * 中间件返回的Cache通过Unwrap返回被包装的Cache，Bridge会通过Unwrap查找Locker、Evictor等可选的功能
+ */
+
+// Middleware 包装Cache，返回新的Cache
+type Middleware func(Cache) Cache
+
+// Chain 把多个中间件合并成一个，第一个中间件在最外层
+func Chain(mws ...Middleware) Middleware {
+	return func(cache Cache) Cache {
+		for i := len(mws) - 1; i >= 0; i-- {
+			cache = mws[i](cache)
+		}
+		return cache
+	}
+}
+
+// 通过Unwrap查找实现了T的Cache
+func findCache[T any](cache Cache) (T, bool) {
+	for cache != nil {
+		if v, ok := cache.(T); ok {
+			return v, true
+		}
+		u, ok := cache.(interface{ Unwrap() Cache })
+		if !ok {
+			break
+		}
+		cache = u.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
+
+// keyMapper 由修改key的中间件(例如KeyPrefix)实现，返回传给被包装的Cache的key
+type keyMapper interface {
+	mapKeys(ctx context.Context, keys []string) ([]string, error)
+}
+
+// 依次通过Unwrap返回的Cache处理keys，得到缓存后端中实际使用的key
+func backendKeys(ctx context.Context, cache Cache, keys []string) ([]string, error) {
+	for cache != nil {
+		if m, ok := cache.(keyMapper); ok {
+			var err error
+			keys, err = m.mapKeys(ctx, keys)
+			if err != nil {
+				return nil, err
+			}
+		}
+		u, ok := cache.(interface{ Unwrap() Cache })
+		if !ok {
+			break
+		}
+		cache = u.Unwrap()
+	}
+	return keys, nil
+}
+
+type timeoutCache struct {
+	Cache
+	timeout time.Duration
+}
+
+// Timeout 每次调用Cache的超时时间
+func Timeout(timeout time.Duration) Middleware {
+	return func(cache Cache) Cache {
+		return &timeoutCache{Cache: cache, timeout: timeout}
+	}
+}
+
+func (c *timeoutCache) Unwrap() Cache {
+	return c.Cache
+}
+
+func (c *timeoutCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.Set(ctx, key, value, expiration)
+}
+
+func (c *timeoutCache) Get(ctx context.Context, key string) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.Get(ctx, key)
+}
+
+func (c *timeoutCache) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.MGet(ctx, keys...)
+}
+
+func (c *timeoutCache) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return mset(ctx, c.Cache, values, expiration)
+}
+
+func (c *timeoutCache) Remove(ctx context.Context, key ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.Cache.Remove(ctx, key...)
+}
+
+type retryCache struct {
+	Cache
+	attempts int
+	backoff  time.Duration
+}
+
+// Retry 调用Cache失败时最多重试attempts次，每次重试之前等待backoff；ErrEmptyCache和ctx取消不会重试
+func Retry(attempts int, backoff time.Duration) Middleware {
+	return func(cache Cache) Cache {
+		return &retryCache{Cache: cache, attempts: attempts, backoff: backoff}
+	}
+}
+
+func (c *retryCache) Unwrap() Cache {
+	return c.Cache
+}
+
+func (c *retryCache) do(ctx context.Context, fn func() error) error {
+	err := fn()
+	for i := 0; i < c.attempts && err != nil && err != errors.ErrEmptyCache; i++ {
+		if c.backoff > 0 {
+			timer := time.NewTimer(c.backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		err = fn()
+	}
+	return err
+}
+
+func (c *retryCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return c.do(ctx, func() error {
+		return c.Cache.Set(ctx, key, value, expiration)
+	})
+}
+
+func (c *retryCache) Get(ctx context.Context, key string) (value interface{}, err error) {
+	err = c.do(ctx, func() error {
+		value, err = c.Cache.Get(ctx, key)
+		return err
+	})
+	return value, err
+}
+
+func (c *retryCache) MGet(ctx context.Context, keys ...string) (values []interface{}, err error) {
+	err = c.do(ctx, func() error {
+		values, err = c.Cache.MGet(ctx, keys...)
+		return err
+	})
+	return values, err
+}
+
+func (c *retryCache) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	return c.do(ctx, func() error {
+		return mset(ctx, c.Cache, values, expiration)
+	})
+}
+
+func (c *retryCache) Remove(ctx context.Context, key ...string) error {
+	return c.do(ctx, func() error {
+		return c.Cache.Remove(ctx, key...)
+	})
+}
+
+type keyPrefixCache struct {
+	Cache
+	prefix string
+}
+
+// KeyPrefix 所有的key都加上prefix(在namespace之后)，填充锁的key不会加上prefix
+func KeyPrefix(prefix string) Middleware {
+	return func(cache Cache) Cache {
+		return &keyPrefixCache{Cache: cache, prefix: prefix}
+	}
+}
+
+func (c *keyPrefixCache) Unwrap() Cache {
+	return c.Cache
+}
+
+func (c *keyPrefixCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return c.Cache.Set(ctx, c.prefix+key, value, expiration)
+}
+
+func (c *keyPrefixCache) Get(ctx context.Context, key string) (interface{}, error) {
+	return c.Cache.Get(ctx, c.prefix+key)
+}
+
+func (c *keyPrefixCache) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	newKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		newKeys = append(newKeys, c.prefix+key)
+	}
+	return c.Cache.MGet(ctx, newKeys...)
+}
+
+func (c *keyPrefixCache) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	newValues := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		newValues[c.prefix+key] = value
+	}
+	return mset(ctx, c.Cache, newValues, expiration)
+}
+
+func (c *keyPrefixCache) Remove(ctx context.Context, key ...string) error {
+	keys := make([]string, 0, len(key))
+	for _, value := range key {
+		keys = append(keys, c.prefix+value)
+	}
+	return c.Cache.Remove(ctx, keys...)
+}
+
+func (c *keyPrefixCache) mapKeys(_ context.Context, keys []string) ([]string, error) {
+	return addPrefix(c.prefix, keys), nil
+}
+
+// Tag tag中记录的是加上prefix之后的key
+func (c *keyPrefixCache) Tag(ctx context.Context, tags []string, expiration time.Duration, keys ...string) error {
+	tagger, err := taggerOf(c.Cache)
+	if err != nil {
+		return err
+	}
+
+	newKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		newKeys = append(newKeys, c.prefix+key)
+	}
+	return tagger.Tag(ctx, tags, expiration, newKeys...)
+}
+
+func (c *keyPrefixCache) InvalidateTags(ctx context.Context, tags ...string) ([]string, error) {
+	tagger, err := taggerOf(c.Cache)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := tagger.InvalidateTags(ctx, tags...)
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, c.prefix)
+	}
+	return keys, err
+}
+
+type readOnlyCache struct {
+	Cache
+}
+
+// ReadOnly 只读缓存，Set、MSet、Remove、Tag、InvalidateTags什么都不做
+func ReadOnly() Middleware {
+	return func(cache Cache) Cache {
+		return &readOnlyCache{Cache: cache}
+	}
+}
+
+func (c *readOnlyCache) Unwrap() Cache {
+	return c.Cache
+}
+
+func (c *readOnlyCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return nil
+}
+
+func (c *readOnlyCache) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	return nil
+}
+
+func (c *readOnlyCache) Remove(ctx context.Context, key ...string) error {
+	return nil
+}
+
+func (c *readOnlyCache) Tag(ctx context.Context, tags []string, expiration time.Duration, keys ...string) error {
+	return nil
+}
+
+func (c *readOnlyCache) InvalidateTags(ctx context.Context, tags ...string) ([]string, error) {
+	return nil, nil
+}
+
+// LatencyRecorderFunc 记录一次Cache调用，op为方法名(Get、MGet、Set、MSet、Remove)，缓存不存在时err为errors.ErrEmptyCache
+type LatencyRecorderFunc func(ctx context.Context, op, namespace string, duration time.Duration, err error)
+
+type latencyCache struct {
+	Cache
+	record LatencyRecorderFunc
+}
+
+// LatencyRecorder 记录每次调用Cache花费的时间
+func LatencyRecorder(record LatencyRecorderFunc) Middleware {
+	return func(cache Cache) Cache {
+		return &latencyCache{Cache: cache, record: record}
+	}
+}
+
+func (c *latencyCache) Unwrap() Cache {
+	return c.Cache
+}
+
+func (c *latencyCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	start := time.Now()
+	err := c.Cache.Set(ctx, key, value, expiration)
+	c.record(ctx, "Set", namespaceOf(c.Cache), time.Since(start), err)
+	return err
+}
+
+func (c *latencyCache) Get(ctx context.Context, key string) (interface{}, error) {
+	start := time.Now()
+	value, err := c.Cache.Get(ctx, key)
+	c.record(ctx, "Get", namespaceOf(c.Cache), time.Since(start), err)
+	return value, err
+}
+
+func (c *latencyCache) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	start := time.Now()
+	values, err := c.Cache.MGet(ctx, keys...)
+	c.record(ctx, "MGet", namespaceOf(c.Cache), time.Since(start), err)
+	return values, err
+}
+
+func (c *latencyCache) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	start := time.Now()
+	err := mset(ctx, c.Cache, values, expiration)
+	c.record(ctx, "MSet", namespaceOf(c.Cache), time.Since(start), err)
+	return err
+}
+
+func (c *latencyCache) Remove(ctx context.Context, key ...string) error {
+	start := time.Now()
+	err := c.Cache.Remove(ctx, key...)
+	c.record(ctx, "Remove", namespaceOf(c.Cache), time.Since(start), err)
+	return err
+}
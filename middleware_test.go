@@ -0,0 +1,107 @@
+package go_cache
+
+import (
+	"context"
+	stdErrors "errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// 前几次调用失败
+type flakyCache struct {
+	*lru.LRU
+	failures int32
+}
+
+func (c *flakyCache) Get(ctx context.Context, key string) (interface{}, error) {
+	if atomic.AddInt32(&c.failures, -1) >= 0 {
+		return nil, errBackendDown
+	}
+	return c.LRU.Get(ctx, key)
+}
+
+// 等到ctx结束
+type slowCache struct {
+	*lru.LRU
+}
+
+func (c *slowCache) Get(ctx context.Context, key string) (interface{}, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestMiddlewareChain(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	var ops []string
+	backend := lru.NewLRU(10)
+	bridge := MustNewBridge(WithCache(backend), WithMiddleware(
+		LatencyRecorder(func(ctx context.Context, op, namespace string, duration time.Duration, err error) {
+			ops = append(ops, op)
+		}),
+		KeyPrefix("v1:"),
+	))
+
+	ret, err := bridge.FetchWithString(ctx, "key", func() (interface{}, time.Duration, error) {
+		return "abc", time.Second, nil
+	})
+	ast.Nil(err)
+	ast.Equal("abc", ret)
+	ast.Equal([]string{"Get", "Set"}, ops)
+
+	value, err := backend.Get(ctx, "v1:key")
+	ast.Nil(err)
+	ast.Equal([]byte("abc"), value)
+
+	err = bridge.Remove(ctx, "key")
+	ast.Nil(err)
+	_, err = backend.Get(ctx, "v1:key")
+	ast.Equal(errors.ErrEmptyCache, err)
+}
+
+func TestMiddlewares(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	// 重试
+	flaky := &flakyCache{LRU: lru.NewLRU(10), failures: 2}
+	_ = flaky.Set(ctx, "key", "abc", time.Second)
+	value, err := Retry(2, time.Millisecond)(flaky).Get(ctx, "key")
+	ast.Nil(err)
+	ast.Equal("abc", value)
+
+	flaky.failures = 2
+	_, err = Retry(1, 0)(flaky).Get(ctx, "key")
+	ast.Equal(errBackendDown, err)
+
+	// 超时
+	start := time.Now()
+	_, err = Timeout(time.Millisecond*10)(&slowCache{LRU: lru.NewLRU(10)}).Get(ctx, "key")
+	ast.True(stdErrors.Is(err, context.DeadlineExceeded))
+	ast.True(time.Since(start) < time.Millisecond*100)
+
+	// 只读
+	readOnly := Chain(ReadOnly(), KeyPrefix("p:"))(lru.NewLRU(10))
+	ast.Nil(readOnly.Set(ctx, "key", "abc", time.Second))
+	_, err = readOnly.Get(ctx, "key")
+	ast.Equal(errors.ErrEmptyCache, err)
+}
+
+func TestMiddlewareUnwrap(t *testing.T) {
+	ast := assert.New(t)
+
+	cache := &lockedCache{LRU: lru.NewLRU(10)}
+	bridge := MustNewBridge(WithCache(cache), WithMiddleware(Timeout(time.Second), ReadOnly()))
+	locker, ok := findCache[Locker](bridge)
+	ast.True(ok)
+	ast.Equal(cache, locker)
+
+	_, ok = findCache[Evictor](bridge)
+	ast.True(ok)
+}
@@ -0,0 +1,177 @@
+package go_cache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/liyanbing/go-cache/errors"
+)
+
+/**
+ * 批量获取多个key的数据
+ * 1、通过一次MGet从cache中获取数据
+ * 2、只把cache中不存在的key交给fetcher批量获取
+ * 3、fetcher获取到的数据通过一次MSet写回cache
+ */
+
+// BatchFetcher 批量获取keys对应的数据，不存在的key不需要出现在返回的map中
+type BatchFetcher[T any] func(ctx context.Context, keys []string) (values map[string]T, expiration time.Duration, err error)
+
+// FetchMulti keys为空时直接返回空的map，重复的key只会获取一次
+func FetchMulti[T any](ctx context.Context, cache Cache, keys []string, fetcher BatchFetcher[T], codec TypedCodec[T]) (map[string]T, error) {
+	keys = uniqueKeys(keys)
+	if len(keys) == 0 {
+		return map[string]T{}, nil
+	}
+
+	cfg := newFetchConfig(ctx, cache)
+	cfg.codec = typedCodecName(codec)
+	namespace := namespaceOf(cache)
+	ret := make(map[string]T, len(keys))
+	newer := make(map[string]struct{}) // 缓存中是新版本数据的key，获取之后不写回
+
+	missing := keys
+	if cfg.policy.canRead() {
+		values, err := cache.MGet(ctx, keys...)
+		if err != nil && err != errors.ErrEmptyCache {
+			// 缓存后端不可用时降级为直接调用fetcher，获取到的数据不写入缓存
+			err = errors.NewBackendError("", strings.Join(keys, ","), err)
+			cfg.reportBackendError(ctx, strings.Join(keys, ","), err)
+			if !cfg.canFallback(ctx) {
+				return nil, err
+			}
+			if !cfg.fallback.acquire() {
+				return nil, err
+			}
+			defer cfg.fallback.release()
+			cfg.policy = PolicyBypass
+			values = nil
+		}
+
+		missing = make([]string, 0, len(keys))
+		now := time.Now()
+		for i, key := range keys {
+			if i >= len(values) || values[i] == nil {
+				cfg.observer.OnMiss(ctx, namespace, key)
+				missing = append(missing, key)
+				continue
+			}
+
+			it := newItem(values[i])
+			if it.notFound {
+				cfg.observer.OnHit(ctx, namespace, key)
+				continue
+			}
+			if it.expired(now) {
+				cfg.observer.OnMiss(ctx, namespace, key)
+				missing = append(missing, key)
+				continue
+			}
+
+			payload, err := cfg.decodePayload(it)
+			if err != nil {
+				err = errors.NewDecodeError(key, err)
+				cfg.observer.OnDecodeError(ctx, namespace, key, err)
+				return nil, err
+			}
+			data, ok := toBytes(payload)
+			if !ok {
+				err = errors.NewDecodeError(key, errors.ErrInvalidCacheValue)
+				cfg.observer.OnDecodeError(ctx, namespace, key, err)
+				return nil, err
+			}
+			value, err := codec.Decode(data)
+			if isSchemaNewer(err) {
+				// 新版本的进程写入的数据，重新获取但是不覆盖
+				newer[key] = struct{}{}
+			}
+			if isSchemaMismatch(err) {
+				cfg.observer.OnMiss(ctx, namespace, key)
+				missing = append(missing, key)
+				continue
+			}
+			if err != nil {
+				err = errors.NewDecodeError(key, err)
+				cfg.observer.OnDecodeError(ctx, namespace, key, err)
+				return nil, err
+			}
+			cfg.observer.OnHit(ctx, namespace, key)
+			ret[key] = value
+		}
+	}
+
+	if len(missing) == 0 || !cfg.policy.canFetch() {
+		return ret, nil
+	}
+
+	start := time.Now()
+	fetched, expires, err := fetcher(ctx, missing)
+	delta := time.Since(start)
+	cfg.observer.OnFetch(ctx, namespace, strings.Join(missing, ","), delta, err)
+	if err != nil {
+		return nil, errors.NewFetcherError(strings.Join(missing, ","), err)
+	}
+
+	for key, value := range fetched {
+		ret[key] = value
+	}
+	if !cfg.policy.canWrite() {
+		return ret, nil
+	}
+
+	var ttl time.Duration
+	cacheValues := make(map[string]interface{}, len(fetched))
+	for key, value := range fetched {
+		if _, ok := newer[key]; ok {
+			continue
+		}
+		cacheData, err := codec.Encode(value)
+		if err != nil {
+			return nil, errors.NewEncodeError(key, err)
+		}
+		cacheValues[key], ttl, err = cfg.cacheValue(value, cacheData, expires, delta)
+		if err != nil {
+			return nil, errors.NewEncodeError(key, err)
+		}
+	}
+
+	if len(cacheValues) == 0 {
+		return ret, nil
+	}
+	err = mset(ctx, cache, cacheValues, ttl)
+	if err == nil {
+		written := make([]string, 0, len(cacheValues))
+		for key := range cacheValues {
+			written = append(written, key)
+		}
+		err = tagWritten(ctx, cache, ttl, written...)
+	}
+	if err != nil {
+		cfg.setFailed(ctx, namespace, strings.Join(missing, ","), err)
+		cfg.logger.Warn("mset cache failed", "keys", missing, "err", err)
+	} else {
+		for key, data := range cacheValues {
+			cfg.observer.OnSet(ctx, namespace, key, dataSize(data))
+		}
+	}
+	return ret, nil
+}
+
+func (t *Typed[T]) FetchMulti(ctx context.Context, keys []string, fetcher BatchFetcher[T]) (map[string]T, error) {
+	return FetchMulti(ctx, t.bridge, keys, fetcher, t.codec)
+}
+
+// 去掉重复的key，保持原来的顺序
+func uniqueKeys(keys []string) []string {
+	seen := make(map[string]struct{}, len(keys))
+	ret := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		ret = append(ret, key)
+	}
+	return ret
+}
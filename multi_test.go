@@ -0,0 +1,111 @@
+package go_cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchMulti(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	bridge := MustNewBridge(WithLRU(10))
+
+	var fetched [][]string
+	fetchFunc := func(ctx context.Context, keys []string) (map[string]*TempModel, time.Duration, error) {
+		fetched = append(fetched, keys)
+		values := make(map[string]*TempModel, len(keys))
+		for _, key := range keys {
+			if key == "user:404" {
+				continue
+			}
+			values[key] = &TempModel{Name: key}
+		}
+		return values, time.Minute, nil
+	}
+
+	users := NewTyped(bridge, JsonCodec[*TempModel]())
+	ret, err := users.FetchMulti(ctx, []string{"user:1", "user:2"}, fetchFunc)
+	ast.Nil(err)
+	ast.Equal(2, len(ret))
+	ast.Equal("user:1", ret["user:1"].Name)
+	ast.Equal([][]string{{"user:1", "user:2"}}, fetched)
+
+	// 只获取不在缓存中的key
+	ret, err = users.FetchMulti(ctx, []string{"user:1", "user:2", "user:3", "user:404"}, fetchFunc)
+	ast.Nil(err)
+	ast.Equal(3, len(ret))
+	ast.Equal("user:3", ret["user:3"].Name)
+	ast.Equal([][]string{{"user:1", "user:2"}, {"user:3", "user:404"}}, fetched)
+
+	// 全部命中缓存
+	ret, err = users.FetchMulti(ctx, []string{"user:1", "user:2", "user:3"}, fetchFunc)
+	ast.Nil(err)
+	ast.Equal(3, len(ret))
+	ast.Equal(2, len(fetched))
+
+	// fetcher失败
+	_, err = users.FetchMulti(ctx, []string{"user:4"}, func(ctx context.Context, keys []string) (map[string]*TempModel, time.Duration, error) {
+		return nil, 0, fmt.Errorf("db error")
+	})
+	ast.NotNil(err)
+}
+
+func TestFetchMulti_WithoutMSet(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	// 没有实现MSet的Cache依次调用Set写入
+	cache := lru.NewLRU(10)
+	fetches := 0
+	fetchFunc := func(ctx context.Context, keys []string) (map[string]string, time.Duration, error) {
+		fetches++
+		values := make(map[string]string, len(keys))
+		for _, key := range keys {
+			values[key] = "value:" + key
+		}
+		return values, time.Minute, nil
+	}
+	for i := 0; i < 2; i++ {
+		ret, err := FetchMulti(ctx, basicCache{cache}, []string{"1", "2"}, fetchFunc, StringCodec())
+		ast.Nil(err)
+		ast.Equal(map[string]string{"1": "value:1", "2": "value:2"}, ret)
+	}
+	ast.Equal(1, fetches)
+	values, err := cache.MGet(ctx, "1", "2")
+	ast.Nil(err)
+	ast.Len(values, 2)
+	ast.NotNil(values[1])
+}
+
+func TestFetchMulti_Keys(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	var fetched [][]string
+	fetchFunc := func(ctx context.Context, keys []string) (map[string]string, time.Duration, error) {
+		fetched = append(fetched, keys)
+		values := make(map[string]string, len(keys))
+		for _, key := range keys {
+			values[key] = "value:" + key
+		}
+		return values, time.Millisecond * 100, nil
+	}
+
+	// 没有key时不访问redis
+	ret, err := FetchMulti(ctx, cache, nil, fetchFunc, StringCodec())
+	ast.Nil(err)
+	ast.Empty(ret)
+	ast.Empty(fetched)
+
+	// 重复的key只获取一次
+	ret, err = FetchMulti(ctx, cache, []string{"multi-dup", "multi-dup"}, fetchFunc, StringCodec())
+	ast.Nil(err)
+	ast.Equal(map[string]string{"multi-dup": "value:multi-dup"}, ret)
+	ast.Equal([][]string{{"multi-dup"}}, fetched)
+	ast.Nil(cache.Remove(ctx, "multi-dup"))
+}
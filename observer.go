@@ -0,0 +1,118 @@
+package go_cache
+
+import (
+	"context"
+	"time"
+)
+
+/**
+ * 观察缓存的命中、未命中、调用fetcher等事件，用于接入监控
+ * 回调是同步调用的，不要在回调中做耗时的操作
+ */
+
+// Observer 缓存事件的回调，namespace为Cache的namespace，key不带namespace
+type Observer interface {
+	// OnHit 从缓存中获取到了数据(包括返回的旧数据和缓存的"不存在")
+	OnHit(ctx context.Context, namespace, key string)
+	// OnMiss 缓存中没有数据或者数据已经过期
+	OnMiss(ctx context.Context, namespace, key string)
+	// OnFetch 调用了fetcher，duration为fetcher花费的时间
+	OnFetch(ctx context.Context, namespace, key string, duration time.Duration, err error)
+	// OnSetError 写缓存失败
+	OnSetError(ctx context.Context, namespace, key string, err error)
+	// OnDecodeError 缓存中的数据解码失败
+	OnDecodeError(ctx context.Context, namespace, key string, err error)
+	// OnCoalesced 和其他并发的调用合并，复用了其他调用的结果
+	OnCoalesced(ctx context.Context, namespace, key string)
+	// OnSet 写缓存成功，size为写入的字节数
+	OnSet(ctx context.Context, namespace, key string, size int)
+	// OnEvict 数据被Cache淘汰(容量不足或者过期)，需要Cache实现Evictor
+	OnEvict(namespace, key string)
+}
+
+// Evictor 可以通知淘汰事件的Cache，例如lru、memory；key不带namespace
+type Evictor interface {
+	SetEvictHandler(fn func(key string))
+}
+
+// NopObserver 什么都不做的Observer，嵌入到自定义的Observer中之后只需要实现关心的方法
+type NopObserver struct{}
+
+func (NopObserver) OnHit(ctx context.Context, namespace, key string) {}
+
+func (NopObserver) OnMiss(ctx context.Context, namespace, key string) {}
+
+func (NopObserver) OnFetch(ctx context.Context, namespace, key string, duration time.Duration, err error) {
+}
+
+func (NopObserver) OnSetError(ctx context.Context, namespace, key string, err error) {}
+
+func (NopObserver) OnDecodeError(ctx context.Context, namespace, key string, err error) {}
+
+func (NopObserver) OnCoalesced(ctx context.Context, namespace, key string) {}
+
+func (NopObserver) OnSet(ctx context.Context, namespace, key string, size int) {}
+
+func (NopObserver) OnEvict(namespace, key string) {}
+
+// 多次调用WithObserver时依次通知每个Observer
+type observers []Observer
+
+func (o observers) OnHit(ctx context.Context, namespace, key string) {
+	for _, ob := range o {
+		ob.OnHit(ctx, namespace, key)
+	}
+}
+
+func (o observers) OnMiss(ctx context.Context, namespace, key string) {
+	for _, ob := range o {
+		ob.OnMiss(ctx, namespace, key)
+	}
+}
+
+func (o observers) OnFetch(ctx context.Context, namespace, key string, duration time.Duration, err error) {
+	for _, ob := range o {
+		ob.OnFetch(ctx, namespace, key, duration, err)
+	}
+}
+
+func (o observers) OnSetError(ctx context.Context, namespace, key string, err error) {
+	for _, ob := range o {
+		ob.OnSetError(ctx, namespace, key, err)
+	}
+}
+
+func (o observers) OnDecodeError(ctx context.Context, namespace, key string, err error) {
+	for _, ob := range o {
+		ob.OnDecodeError(ctx, namespace, key, err)
+	}
+}
+
+func (o observers) OnCoalesced(ctx context.Context, namespace, key string) {
+	for _, ob := range o {
+		ob.OnCoalesced(ctx, namespace, key)
+	}
+}
+
+func (o observers) OnSet(ctx context.Context, namespace, key string, size int) {
+	for _, ob := range o {
+		ob.OnSet(ctx, namespace, key, size)
+	}
+}
+
+func (o observers) OnEvict(namespace, key string) {
+	for _, ob := range o {
+		ob.OnEvict(namespace, key)
+	}
+}
+
+func appendObserver(o Observer, ob Observer) Observer {
+	switch v := o.(type) {
+	case nil:
+		return ob
+	case observers:
+		return append(v[:len(v):len(v)], ob)
+	default:
+		return observers{v, ob}
+	}
+}
@@ -0,0 +1,131 @@
+package go_cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordObserver struct {
+	NopObserver
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordObserver) record(event, namespace, key string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, fmt.Sprintf("%v %v:%v", event, namespace, key))
+}
+
+func (o *recordObserver) OnHit(ctx context.Context, namespace, key string) {
+	o.record("hit", namespace, key)
+}
+
+func (o *recordObserver) OnMiss(ctx context.Context, namespace, key string) {
+	o.record("miss", namespace, key)
+}
+
+func (o *recordObserver) OnFetch(ctx context.Context, namespace, key string, duration time.Duration, err error) {
+	o.record("fetch", namespace, key)
+}
+
+func (o *recordObserver) OnDecodeError(ctx context.Context, namespace, key string, err error) {
+	o.record("decode", namespace, key)
+}
+
+func (o *recordObserver) OnCoalesced(ctx context.Context, namespace, key string) {
+	o.record("coalesced", namespace, key)
+}
+
+func (o *recordObserver) count(event string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := 0
+	for _, e := range o.events {
+		if len(e) > len(event) && e[:len(event)+1] == event+" " {
+			n++
+		}
+	}
+	return n
+}
+
+func TestObserver(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	ob := &recordObserver{}
+	bridge := MustNewBridge(WithLRU(10), WithObserver(ob))
+	bridge.SetNamespace("ob")
+
+	fetchFunc := func() (interface{}, time.Duration, error) {
+		return "abc", time.Second, nil
+	}
+	_, err := bridge.FetchWithString(ctx, "key", fetchFunc)
+	ast.Nil(err)
+	_, err = bridge.FetchWithString(ctx, "key", fetchFunc)
+	ast.Nil(err)
+	ast.Equal([]string{"miss ob:key", "fetch ob:key", "hit ob:key"}, ob.events)
+
+	// 解码失败
+	err = bridge.Set(ctx, "json-key", "{invalid", time.Second)
+	ast.Nil(err)
+	_, err = bridge.FetchWithJson(ctx, "json-key", fetchFunc, &TempModel{})
+	ast.NotNil(err)
+	ast.Equal(1, ob.count("decode"))
+
+	// FetchWithIncludeKeys
+	err = bridge.FetchWithIncludeKeys(ctx, func(value interface{}) error {
+		return nil
+	}, func(key string) {}, func(value interface{}) (interface{}, error) {
+		return value, nil
+	}, "key", "empty-key")
+	ast.Nil(err)
+	ast.Equal(2, ob.count("hit"))
+	ast.Equal(2, ob.count("miss"))
+
+	// 并发调用被合并
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := bridge.FetchWithString(ctx, "slow-key", func() (interface{}, time.Duration, error) {
+				time.Sleep(time.Millisecond * 50)
+				return "abc", time.Second, nil
+			})
+			ast.Nil(err)
+		}()
+	}
+	wg.Wait()
+	ast.Equal(4, ob.count("coalesced"))
+}
+
+// 只实现了Cache接口的第三方Cache
+type basicCache struct {
+	Cache
+}
+
+func TestObserver_WithoutNamespace(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	ob := &recordObserver{}
+	bridge := MustNewBridge(WithCache(basicCache{lru.NewLRU(10)}), WithObserver(ob))
+	ast.Equal("", bridge.Namespace())
+
+	fetchFunc := func() (interface{}, time.Duration, error) {
+		return "abc", time.Second, nil
+	}
+	_, err := bridge.FetchWithString(ctx, "key", fetchFunc)
+	ast.Nil(err)
+	ret, err := bridge.FetchWithString(ctx, "key", fetchFunc)
+	ast.Nil(err)
+	ast.Equal("abc", ret)
+	ast.Equal([]string{"miss :key", "fetch :key", "hit :key"}, ob.events)
+}
@@ -0,0 +1,56 @@
+package go_cache
+
+/**
+ * 编码之后、写入缓存之前对数据的处理，读取时按照相反的顺序处理
+ * 写入：encoder -> 压缩(WithCompression) -> 加密(WithEncryption) -> 元数据(item)
+ * 读取：元数据(item) -> 解密 -> 解压 -> Decoder
+ */
+
+// 写入缓存之前处理编码之后的数据，返回压缩算法的ID(没有压缩时为compressNone)
+func (c fetchConfig) encodePayload(data []byte) (byte, []byte, error) {
+	compressor := compressNone
+	var err error
+	if c.compression != nil {
+		compressor, data, err = c.compression.compress(data)
+		if err != nil {
+			return compressNone, nil, err
+		}
+	}
+	if c.encryption != nil {
+		data, err = c.encryption.encryptor.Encrypt(data)
+		if err != nil {
+			return compressNone, nil, err
+		}
+	}
+	return compressor, data, nil
+}
+
+// 从缓存中读取的数据解码之前的处理；只有元数据中记录了压缩算法时才解压，关闭压缩之后仍然可以读取压缩过的数据
+func (c fetchConfig) decodePayload(it *item) (interface{}, error) {
+	data := it.payload
+	if c.encryption != nil {
+		var err error
+		data, err = c.encryption.decrypt(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if it.compressor == compressNone {
+		return data, nil
+	}
+
+	byteData, ok := toBytes(data)
+	if !ok {
+		return data, nil
+	}
+	return decompress(it.compressor, byteData)
+}
+
+// 处理缓存中的payload之后通过d解码
+func (c fetchConfig) decode(d Decoder, it *item) (interface{}, error) {
+	data, err := c.decodePayload(it)
+	if err != nil {
+		return nil, err
+	}
+	return d(data)
+}
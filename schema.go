@@ -0,0 +1,141 @@
+package go_cache
+
+import (
+	"encoding/binary"
+	stdErrors "errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/liyanbing/go-cache/errors"
+)
+
+/**
+ * model的schema版本
+ * 1、通过RegisterSchema为model注册当前的版本和从旧版本升级的函数，json和protobuf编码之后的数据前面会加上版本号：
+ *    schemaMagic(1字节) + 版本号(uvarint) + 编码之后的数据
+ * 2、解码时版本号比注册的版本旧时依次调用升级函数，没有升级函数或者版本号比注册的版本新时返回errors.ErrSchemaMismatch，
+ *    fetch会当作缓存中不存在，重新调用fetcher并写入新版本的数据；
+ *    版本号比注册的版本新时(滚动发布时新版本的进程写入的)只返回fetcher的数据，不会用旧版本的数据覆盖
+ * 3、没有版本号的数据(注册之前写入的)的版本为0，没有注册schema的model不会写入版本号
+ * schemaMagic(0x1f)不会出现在json和protobuf(wire type为7)的开头
+ */
+
+const schemaMagic byte = 0x1f
+
+// MigrationFunc 把版本为from的数据升级为from+1，data为去掉版本号之后编码的数据
+type MigrationFunc func(data []byte) ([]byte, error)
+
+type schema struct {
+	version    uint64
+	migrations map[uint64]MigrationFunc
+}
+
+var schemas sync.Map // reflect.Type -> *schema
+
+// RegisterSchema 注册model(对象或者对象指针)当前的schema版本(大于0)，migrations[v]为从版本v升级到v+1的函数；
+// 版本0为注册之前写入的没有版本号的数据，没有migrations[0]时注册之前写入的数据都会重新获取
+func RegisterSchema(model interface{}, version uint64, migrations map[uint64]MigrationFunc) {
+	s := &schema{
+		version:    version,
+		migrations: make(map[uint64]MigrationFunc, len(migrations)),
+	}
+	for from, fn := range migrations {
+		s.migrations[from] = fn
+	}
+	schemas.Store(typeFromModel(model), s)
+}
+
+func schemaOf(typ reflect.Type) (*schema, bool) {
+	if typ == nil {
+		return nil, false
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	s, ok := schemas.Load(typ)
+	if !ok {
+		return nil, false
+	}
+	return s.(*schema), true
+}
+
+// 注册了schema时在编码之后的数据前面加上版本号
+func encodeSchema(typ reflect.Type, data []byte) []byte {
+	s, ok := schemaOf(typ)
+	if !ok {
+		return data
+	}
+	ret := make([]byte, 0, 1+binary.MaxVarintLen64+len(data))
+	ret = append(ret, schemaMagic)
+	ret = appendUvarint(ret, s.version)
+	return append(ret, data...)
+}
+
+// 只有json和protobuf会在编码之后的数据前面加上版本号，其他codec的数据(例如gob以0x1f开头)不能解析版本号
+func writesSchema(codec string) bool {
+	return codec == CodecJson || codec == CodecProtobuf
+}
+
+// 去掉数据中的版本号，返回版本号，没有版本号时为0
+func splitSchema(data []byte) (uint64, []byte) {
+	if len(data) < 2 || data[0] != schemaMagic {
+		return 0, data
+	}
+	version, n := binary.Uvarint(data[1:])
+	if n <= 0 {
+		return 0, data
+	}
+	return version, data[1+n:]
+}
+
+// 去掉版本号，版本号比注册的版本旧时升级到注册的版本
+func decodeSchema(typ reflect.Type, data []byte) ([]byte, error) {
+	version, data := splitSchema(data)
+	s, ok := schemaOf(typ)
+	if !ok {
+		return data, nil
+	}
+	if version > s.version {
+		return nil, &schemaNewerError{typ: typ, version: version, current: s.version}
+	}
+
+	for ; version < s.version; version++ {
+		migrate, ok := s.migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: %v can not migrate from version %d", errors.ErrSchemaMismatch, typ, version)
+		}
+		var err error
+		data, err = migrate(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v migrate from version %d: %v", errors.ErrSchemaMismatch, typ, version, err)
+		}
+	}
+	return data, nil
+}
+
+// 数据的版本号比注册的版本新
+type schemaNewerError struct {
+	typ     reflect.Type
+	version uint64
+	current uint64
+}
+
+func (e *schemaNewerError) Error() string {
+	return fmt.Sprintf("%v: %v version %d is newer than %d", errors.ErrSchemaMismatch, e.typ, e.version, e.current)
+}
+
+func (e *schemaNewerError) Unwrap() error {
+	return errors.ErrSchemaMismatch
+}
+
+// 缓存中是新版本的数据，当作缓存中不存在，但是不能写入旧版本的数据
+func isSchemaNewer(err error) bool {
+	var e *schemaNewerError
+	return err != nil && stdErrors.As(err, &e)
+}
+
+// Decoder返回的错误是否需要当作缓存中不存在
+func isSchemaMismatch(err error) bool {
+	return err != nil && stdErrors.Is(err, errors.ErrSchemaMismatch)
+}
@@ -0,0 +1,156 @@
+package go_cache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/stretchr/testify/assert"
+)
+
+type schemaUser struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Age       int    `json:"age"`
+}
+
+type schemaOrder struct {
+	Id     int    `json:"id"`
+	Status string `json:"status"`
+}
+
+func init() {
+	RegisterSchema(schemaUser{}, 2, map[uint64]MigrationFunc{
+		// 版本0的name拆分为first_name和last_name
+		0: func(data []byte) ([]byte, error) {
+			var old map[string]interface{}
+			if err := json.Unmarshal(data, &old); err != nil {
+				return nil, err
+			}
+			name, _ := old["name"].(string)
+			first, last, _ := bytes.Cut([]byte(name), []byte(" "))
+			delete(old, "name")
+			old["first_name"], old["last_name"] = string(first), string(last)
+			return json.Marshal(old)
+		},
+		// 版本1没有age
+		1: func(data []byte) ([]byte, error) {
+			return append(data[:len(data)-1], []byte(`,"age":18}`)...), nil
+		},
+	})
+	RegisterSchema(&schemaOrder{}, 1, nil)
+}
+
+func TestSchemaUpcast(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	cache := lru.NewLRU(10)
+	bridge := MustNewBridge(WithCache(cache), WithEnvelope())
+	fetches := 0
+	fetcher := func() (interface{}, time.Duration, error) {
+		fetches++
+		return &schemaUser{FirstName: "new", Age: 30}, time.Minute, nil
+	}
+
+	ast.Nil(cache.Set(ctx, "v0", []byte(`{"name":"peter pan"}`), time.Minute))
+	value, err := bridge.FetchWithJson(ctx, "v0", fetcher, &schemaUser{})
+	ast.Nil(err)
+	ast.Equal(&schemaUser{FirstName: "peter", LastName: "pan", Age: 18}, value)
+
+	ast.Nil(cache.Set(ctx, "v1", append([]byte{schemaMagic, 1}, `{"first_name":"tom","last_name":"sawyer"}`...), time.Minute))
+	value, err = bridge.FetchWithJson(ctx, "v1", fetcher, &schemaUser{})
+	ast.Nil(err)
+	ast.Equal(&schemaUser{FirstName: "tom", LastName: "sawyer", Age: 18}, value)
+	ast.Equal(0, fetches)
+
+	// 写入当前的版本
+	_, err = bridge.FetchWithJson(ctx, "v2", fetcher, &schemaUser{})
+	ast.Nil(err)
+	ast.Equal(1, fetches)
+	data, err := cache.Get(ctx, "v2")
+	ast.Nil(err)
+	e, ok := ParseEnvelope(data)
+	ast.True(ok)
+	ast.Equal(uint64(2), e.SchemaVersion)
+
+	value, err = bridge.FetchWithJson(ctx, "v2", fetcher, &schemaUser{})
+	ast.Nil(err)
+	ast.Equal(&schemaUser{FirstName: "new", Age: 30}, value)
+	ast.Equal(1, fetches)
+
+	// 直接使用Decoder
+	value, err = JsonDecode(schemaUser{})(data)
+	ast.Nil(err)
+	ast.Equal(&schemaUser{FirstName: "new", Age: 30}, value)
+}
+
+func TestSchemaMismatch(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	cache := lru.NewLRU(10)
+	bridge := MustNewBridge(WithCache(cache))
+	fetches := 0
+	fetcher := func() (interface{}, time.Duration, error) {
+		fetches++
+		return &schemaOrder{Id: 1, Status: "paid"}, time.Minute, nil
+	}
+
+	// 没有从版本0升级的函数，重新获取并写入当前版本的数据
+	ast.Nil(cache.Set(ctx, "legacy", []byte(`{"id":1,"state":1}`), time.Minute))
+	for i := 0; i < 2; i++ {
+		value, err := bridge.FetchWithJson(ctx, "legacy", fetcher, &schemaOrder{})
+		ast.Nil(err)
+		ast.Equal(&schemaOrder{Id: 1, Status: "paid"}, value)
+	}
+	ast.Equal(1, fetches)
+
+	// 比当前版本新的数据(新版本的进程写入的)，重新获取但是不覆盖
+	newer := append([]byte{schemaMagic, 2}, `{"id":1}`...)
+	ast.Nil(cache.Set(ctx, "newer", newer, time.Minute))
+	for i := 0; i < 2; i++ {
+		value, err := bridge.FetchWithJson(ctx, "newer", fetcher, &schemaOrder{})
+		ast.Nil(err)
+		ast.Equal(&schemaOrder{Id: 1, Status: "paid"}, value)
+	}
+	ast.Equal(3, fetches)
+	data, err := cache.Get(ctx, "newer")
+	ast.Nil(err)
+	ast.Equal(newer, data)
+
+	// 泛型版本
+	ast.Nil(cache.Set(ctx, "typed", []byte(`{"id":2}`), time.Minute))
+	order, err := Fetch(ctx, bridge, "typed", func(ctx context.Context) (*schemaOrder, time.Duration, error) {
+		return &schemaOrder{Id: 2, Status: "new"}, time.Minute, nil
+	}, JsonCodec[*schemaOrder]())
+	ast.Nil(err)
+	ast.Equal("new", order.Status)
+
+	ast.Nil(cache.Set(ctx, "multi", []byte(`{"id":3}`), time.Minute))
+	orders, err := FetchMulti(ctx, bridge, []string{"typed", "multi", "newer"}, func(ctx context.Context, keys []string) (map[string]schemaOrder, time.Duration, error) {
+		ast.Equal([]string{"multi", "newer"}, keys)
+		return map[string]schemaOrder{"multi": {Id: 3, Status: "new"}, "newer": {Id: 1, Status: "paid"}}, time.Minute, nil
+	}, JsonCodec[schemaOrder]())
+	ast.Nil(err)
+	ast.Equal(map[string]schemaOrder{"typed": {Id: 2, Status: "new"}, "multi": {Id: 3, Status: "new"}, "newer": {Id: 1, Status: "paid"}}, orders)
+	data, err = cache.Get(ctx, "newer")
+	ast.Nil(err)
+	ast.Equal(newer, data)
+}
+
+func TestSchemaEnvelope_Codec(t *testing.T) {
+	ast := assert.New(t)
+
+	// 只有json、protobuf的数据会解析版本号，gob编码的数据可能以0x1f开头
+	payload := []byte{schemaMagic, 0x03, 0xff, 0x81}
+	for codec, version := range map[string]uint64{CodecJson: 3, CodecGob: 0} {
+		it := &item{codec: codec, payload: payload, createdAt: time.Now().UnixNano()}
+		e, ok := ParseEnvelope(it.marshal())
+		ast.True(ok)
+		ast.Equal(codec, e.Codec)
+		ast.Equal(version, e.SchemaVersion, codec)
+	}
+}
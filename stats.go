@@ -0,0 +1,239 @@
+package go_cache
+
+import (
+	"context"
+	"expvar"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/**
+ * 内置的统计，按照namespace和key的前缀分别计数
+ * Stats实现了Observer，通过WithObserver设置到Bridge上，多个Bridge可以共用一个Stats
+ * 可以通过Snapshot获取统计数据，或者通过Publish发布到expvar(/debug/vars)
+ */
+
+// fetcher耗时直方图的区间上限，最后一个区间没有上限
+var statsLatencyBuckets = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+type StatsOption func(*Stats)
+
+// WithStatsKeyPrefix 设置从key中获取前缀的方法，默认为第一个":"之前的部分，返回空字符串时不按前缀统计
+func WithStatsKeyPrefix(fn func(key string) string) StatsOption {
+	return func(s *Stats) {
+		s.keyPrefix = fn
+	}
+}
+
+// 默认的key前缀，例如user:1的前缀为user
+func defaultKeyPrefix(key string) string {
+	if i := strings.IndexByte(key, ':'); i > 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+type Stats struct {
+	keyPrefix  func(key string) string
+	namespaces sync.Map // namespace -> *statsCounter
+	prefixes   sync.Map // key前缀 -> *statsCounter
+}
+
+var _ Observer = (*Stats)(nil)
+
+func NewStats(opts ...StatsOption) *Stats {
+	s := &Stats{
+		keyPrefix: defaultKeyPrefix,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// 字段都是int64，通过atomic读写，statsCounter总是单独分配，32位平台上也是8字节对齐的
+type statsCounter struct {
+	hits         int64
+	misses       int64
+	coalesced    int64
+	evictions    int64
+	bytesWritten int64
+	fetches      int64
+	fetchErrors  int64
+	setErrors    int64
+	decodeErrors int64
+	latencySum   int64
+	latency      [len(statsLatencyBuckets) + 1]int64
+}
+
+func counterOf(m *sync.Map, name string) *statsCounter {
+	if c, ok := m.Load(name); ok {
+		return c.(*statsCounter)
+	}
+	c, _ := m.LoadOrStore(name, &statsCounter{})
+	return c.(*statsCounter)
+}
+
+// 对namespace和key前缀的计数器分别调用fn
+func (s *Stats) add(namespace, key string, fn func(c *statsCounter)) {
+	fn(counterOf(&s.namespaces, namespace))
+	if s.keyPrefix == nil {
+		return
+	}
+	if prefix := s.keyPrefix(key); prefix != "" {
+		fn(counterOf(&s.prefixes, prefix))
+	}
+}
+
+func (s *Stats) OnHit(ctx context.Context, namespace, key string) {
+	s.add(namespace, key, func(c *statsCounter) { atomic.AddInt64(&c.hits, 1) })
+}
+
+func (s *Stats) OnMiss(ctx context.Context, namespace, key string) {
+	s.add(namespace, key, func(c *statsCounter) { atomic.AddInt64(&c.misses, 1) })
+}
+
+func (s *Stats) OnFetch(ctx context.Context, namespace, key string, duration time.Duration, err error) {
+	bucket := len(statsLatencyBuckets)
+	for i, upper := range statsLatencyBuckets {
+		if duration <= upper {
+			bucket = i
+			break
+		}
+	}
+
+	s.add(namespace, key, func(c *statsCounter) {
+		atomic.AddInt64(&c.fetches, 1)
+		if err != nil {
+			atomic.AddInt64(&c.fetchErrors, 1)
+		}
+		atomic.AddInt64(&c.latencySum, int64(duration))
+		atomic.AddInt64(&c.latency[bucket], 1)
+	})
+}
+
+func (s *Stats) OnSetError(ctx context.Context, namespace, key string, err error) {
+	s.add(namespace, key, func(c *statsCounter) { atomic.AddInt64(&c.setErrors, 1) })
+}
+
+func (s *Stats) OnDecodeError(ctx context.Context, namespace, key string, err error) {
+	s.add(namespace, key, func(c *statsCounter) { atomic.AddInt64(&c.decodeErrors, 1) })
+}
+
+func (s *Stats) OnCoalesced(ctx context.Context, namespace, key string) {
+	s.add(namespace, key, func(c *statsCounter) { atomic.AddInt64(&c.coalesced, 1) })
+}
+
+func (s *Stats) OnSet(ctx context.Context, namespace, key string, size int) {
+	s.add(namespace, key, func(c *statsCounter) { atomic.AddInt64(&c.bytesWritten, int64(size)) })
+}
+
+func (s *Stats) OnEvict(namespace, key string) {
+	s.add(namespace, key, func(c *statsCounter) { atomic.AddInt64(&c.evictions, 1) })
+}
+
+// StatsSnapshot 某一时刻的统计数据
+type StatsSnapshot struct {
+	Namespaces map[string]StatsCounters `json:"namespaces"`
+	Prefixes   map[string]StatsCounters `json:"prefixes"`
+}
+
+type StatsCounters struct {
+	Hits         int64            `json:"hits"`
+	Misses       int64            `json:"misses"`
+	Coalesced    int64            `json:"coalesced"`
+	Evictions    int64            `json:"evictions"`
+	BytesWritten int64            `json:"bytes_written"`
+	Fetches      int64            `json:"fetches"`
+	FetchErrors  int64            `json:"fetch_errors"`
+	SetErrors    int64            `json:"set_errors"`
+	DecodeErrors int64            `json:"decode_errors"`
+	FetchLatency LatencyHistogram `json:"fetch_latency"`
+}
+
+// HitRatio 命中率，没有请求时为0
+func (c StatsCounters) HitRatio() float64 {
+	total := c.Hits + c.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(c.Hits) / float64(total)
+}
+
+// LatencyHistogram fetcher耗时的直方图，Buckets中的计数不是累计的
+type LatencyHistogram struct {
+	Count   int64           `json:"count"`
+	Sum     time.Duration   `json:"sum"`
+	Buckets []LatencyBucket `json:"buckets"`
+}
+
+// LatencyBucket 耗时在(上一个区间的UpperBound, UpperBound]之间的次数，UpperBound为0表示没有上限
+type LatencyBucket struct {
+	UpperBound time.Duration `json:"upper_bound"`
+	Count      int64         `json:"count"`
+}
+
+func (c *statsCounter) snapshot() StatsCounters {
+	ret := StatsCounters{
+		Hits:         atomic.LoadInt64(&c.hits),
+		Misses:       atomic.LoadInt64(&c.misses),
+		Coalesced:    atomic.LoadInt64(&c.coalesced),
+		Evictions:    atomic.LoadInt64(&c.evictions),
+		BytesWritten: atomic.LoadInt64(&c.bytesWritten),
+		Fetches:      atomic.LoadInt64(&c.fetches),
+		FetchErrors:  atomic.LoadInt64(&c.fetchErrors),
+		SetErrors:    atomic.LoadInt64(&c.setErrors),
+		DecodeErrors: atomic.LoadInt64(&c.decodeErrors),
+		FetchLatency: LatencyHistogram{
+			Sum:     time.Duration(atomic.LoadInt64(&c.latencySum)),
+			Buckets: make([]LatencyBucket, 0, len(c.latency)),
+		},
+	}
+	for i := range c.latency {
+		bucket := LatencyBucket{Count: atomic.LoadInt64(&c.latency[i])}
+		if i < len(statsLatencyBuckets) {
+			bucket.UpperBound = statsLatencyBuckets[i]
+		}
+		ret.FetchLatency.Count += bucket.Count
+		ret.FetchLatency.Buckets = append(ret.FetchLatency.Buckets, bucket)
+	}
+	return ret
+}
+
+func snapshotOf(m *sync.Map) map[string]StatsCounters {
+	ret := make(map[string]StatsCounters)
+	m.Range(func(key, value interface{}) bool {
+		ret[key.(string)] = value.(*statsCounter).snapshot()
+		return true
+	})
+	return ret
+}
+
+func (s *Stats) Snapshot() StatsSnapshot {
+	return StatsSnapshot{
+		Namespaces: snapshotOf(&s.namespaces),
+		Prefixes:   snapshotOf(&s.prefixes),
+	}
+}
+
+// String 实现expvar.Var，返回json格式的统计数据
+func (s *Stats) String() string {
+	data, _ := json.Marshal(s.Snapshot())
+	return string(data)
+}
+
+// Publish 通过expvar发布统计数据，name不能重复
+func (s *Stats) Publish(name string) {
+	expvar.Publish(name, s)
+}
@@ -0,0 +1,55 @@
+package go_cache
+
+import (
+	"context"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	stats := NewStats()
+	bridge := MustNewBridge(WithCache(lru.NewLRU(2)), WithObserver(stats))
+	bridge.SetNamespace("stats")
+
+	fetchFunc := func() (interface{}, time.Duration, error) {
+		return "abc", time.Second, nil
+	}
+	for _, key := range []string{"user:1", "user:1", "user:2", "order:1"} {
+		ret, err := bridge.FetchWithString(ctx, key, fetchFunc)
+		ast.Nil(err)
+		ast.Equal("abc", ret)
+	}
+
+	snapshot := stats.Snapshot()
+	ns := snapshot.Namespaces["stats"]
+	ast.EqualValues(1, ns.Hits)
+	ast.EqualValues(3, ns.Misses)
+	ast.EqualValues(3, ns.Fetches)
+	ast.EqualValues(9, ns.BytesWritten)
+	ast.EqualValues(3, ns.FetchLatency.Count)
+	ast.EqualValues(3, ns.FetchLatency.Buckets[0].Count)
+	ast.EqualValues(0.25, ns.HitRatio())
+	// 容量为2，写入order:1时淘汰了user:1
+	ast.EqualValues(1, ns.Evictions)
+
+	user := snapshot.Prefixes["user"]
+	ast.EqualValues(1, user.Hits)
+	ast.EqualValues(2, user.Misses)
+	ast.EqualValues(1, user.Evictions)
+	ast.EqualValues(1, snapshot.Prefixes["order"].Misses)
+
+	// 主动删除不是淘汰
+	err := bridge.Remove(ctx, "order:1")
+	ast.Nil(err)
+	ast.EqualValues(1, stats.Snapshot().Namespaces["stats"].Evictions)
+
+	stats.Publish("go_cache_stats_test")
+	ast.Equal(stats.String(), expvar.Get("go_cache_stats_test").String())
+}
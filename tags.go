@@ -0,0 +1,74 @@
+package go_cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/liyanbing/go-cache/errors"
+)
+
+/**
+ * 基于tag的批量删除
+ * 通过WithTags(ctx, tags...)给写入的key加上tag，缓存后端记录tag到key的对应关系(redis为集合，lru、memory为进程内的索引)，
+ * 之后通过Bridge.InvalidateTags删除tag下所有的key；Bridge和包级的FetchWith*都会给写入的key加上tag
+ */
+
+// Tagger 支持tag的Cache，key不带namespace
+type Tagger interface {
+	// Tag 给keys加上tags，expiration为keys的过期时间
+	Tag(ctx context.Context, tags []string, expiration time.Duration, keys ...string) error
+	// InvalidateTags 删除tags下所有的key，返回删除的key
+	InvalidateTags(ctx context.Context, tags ...string) ([]string, error)
+}
+
+func taggerOf(cache Cache) (Tagger, error) {
+	tagger, ok := findCache[Tagger](cache)
+	if !ok {
+		return nil, fmt.Errorf("%w: cache does not support tags", errors.ErrNotSupported)
+	}
+	return tagger, nil
+}
+
+// 写入成功之后给keys加上ctx中的tags，cache不支持tag时返回errors.ErrNotSupported
+func tagKeys(ctx context.Context, cache Cache, expiration time.Duration, keys ...string) error {
+	tags := tagsFromContext(ctx)
+	if len(tags) == 0 || len(keys) == 0 {
+		return nil
+	}
+
+	tagger, err := taggerOf(cache)
+	if err != nil {
+		return err
+	}
+	return tagger.Tag(ctx, tags, expiration, keys...)
+}
+
+// fetch写入之后给keys加上ctx中的tags，Bridge的Set、MSet中已经加上了tag
+func tagWritten(ctx context.Context, cache Cache, expiration time.Duration, keys ...string) error {
+	if _, ok := cache.(*bridger); ok {
+		return nil
+	}
+	return tagKeys(ctx, cache, expiration, keys...)
+}
+
+func (c *bridger) tag(ctx context.Context, expiration time.Duration, keys ...string) error {
+	return tagKeys(ctx, c.Cache, expiration, keys...)
+}
+
+// InvalidateTags 删除tags下所有的key，开启了WithInvalidation时会通知其他进程；和Remove一样不受Policy影响
+func (c *bridger) InvalidateTags(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	tagger, err := taggerOf(c.Cache)
+	if err != nil {
+		return err
+	}
+	keys, err := tagger.InvalidateTags(ctx, tags...)
+	if err != nil || c.bus == nil || len(keys) == 0 {
+		return err
+	}
+	return c.publishKeys(ctx, keys...)
+}
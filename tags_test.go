@@ -0,0 +1,119 @@
+package go_cache
+
+import (
+	"context"
+	stdErrors "errors"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/liyanbing/go-cache/cacher/memory"
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// 不支持tag的Cache
+type untaggedCache struct {
+	Cache
+}
+
+func TestBridgeInvalidateTags(t *testing.T) {
+	ast := assert.New(t)
+
+	for _, cache := range []Cache{lru.NewLRU(10), memory.NewMemoryCache(10)} {
+		bridge := MustNewBridge(WithCache(cache))
+		bridge.SetNamespace("tags")
+
+		fetches := 0
+		fetcher := func() (interface{}, time.Duration, error) {
+			fetches++
+			return "value", time.Minute, nil
+		}
+
+		ctx := WithTags(context.Background(), "user:1")
+		_, err := bridge.FetchWithString(ctx, "profile:1", fetcher)
+		ast.Nil(err)
+		err = bridge.Set(WithTags(ctx, "order"), "orders:1", "value", time.Minute)
+		ast.Nil(err)
+		err = bridge.Set(context.Background(), "other", "value", time.Minute)
+		ast.Nil(err)
+
+		err = bridge.InvalidateTags(context.Background(), "user:1")
+		ast.Nil(err)
+
+		_, err = bridge.Get(context.Background(), "profile:1")
+		ast.Equal(errors.ErrEmptyCache, err)
+		_, err = bridge.Get(context.Background(), "orders:1")
+		ast.Equal(errors.ErrEmptyCache, err)
+		_, err = bridge.Get(context.Background(), "other")
+		ast.Nil(err)
+
+		// 删除之后重新获取
+		_, err = bridge.FetchWithString(ctx, "profile:1", fetcher)
+		ast.Nil(err)
+		ast.Equal(2, fetches)
+
+		// 已经删除的tag
+		err = bridge.InvalidateTags(context.Background(), "user:1", "order")
+		ast.Nil(err)
+		_, err = bridge.Get(context.Background(), "profile:1")
+		ast.Equal(errors.ErrEmptyCache, err)
+	}
+}
+
+func TestBridgeInvalidateTags_KeyPrefix(t *testing.T) {
+	ast := assert.New(t)
+
+	bridge := MustNewBridge(WithLRU(10), WithMiddleware(KeyPrefix("v1:")))
+	ctx := WithTags(context.Background(), "user")
+	ast.Nil(bridge.Set(ctx, "name", "value", time.Minute))
+
+	ast.Nil(bridge.InvalidateTags(context.Background(), "user"))
+	_, err := bridge.Get(context.Background(), "name")
+	ast.Equal(errors.ErrEmptyCache, err)
+}
+
+func TestBridgeInvalidateTags_NotSupported(t *testing.T) {
+	ast := assert.New(t)
+
+	bridge := MustNewBridge(WithCache(&untaggedCache{Cache: lru.NewLRU(10)}))
+	err := bridge.Set(WithTags(context.Background(), "user"), "name", "value", time.Minute)
+	ast.True(stdErrors.Is(err, errors.ErrNotSupported))
+
+	err = bridge.InvalidateTags(context.Background(), "user")
+	ast.True(stdErrors.Is(err, errors.ErrNotSupported))
+}
+
+func TestBridgeInvalidateTags_Policy(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	// 主动删除不受策略影响
+	bridge := MustNewBridge(WithLRU(10))
+	for _, policy := range []Policy{PolicyReadOnly, PolicyBypass, PolicyCacheOnly} {
+		ast.Nil(bridge.Set(WithTags(ctx, "user"), "name", "value", time.Minute))
+		ast.Nil(bridge.InvalidateTags(WithPolicy(ctx, policy), "user"))
+		_, err := bridge.Get(ctx, "name")
+		ast.Equal(errors.ErrEmptyCache, err)
+	}
+}
+
+func TestFetchWithTags(t *testing.T) {
+	ast := assert.New(t)
+	ctx := WithTags(context.Background(), "user")
+
+	// 包级的FetchWith*同样会给写入的key加上tag
+	cache := lru.NewLRU(10)
+	_, err := FetchWithString(ctx, cache, "name", func() (interface{}, time.Duration, error) {
+		return "value", time.Minute, nil
+	})
+	ast.Nil(err)
+	_, err = FetchMulti(ctx, cache, []string{"a", "b"}, func(ctx context.Context, keys []string) (map[string]string, time.Duration, error) {
+		return map[string]string{"a": "1", "b": "2"}, time.Minute, nil
+	}, StringCodec())
+	ast.Nil(err)
+
+	keys, err := cache.InvalidateTags(context.Background(), "user")
+	ast.Nil(err)
+	ast.ElementsMatch([]string{"name", "a", "b"}, keys)
+}
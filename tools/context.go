@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"context"
+	"time"
+)
+
+// WithoutCancel 返回保留parent中的值、但不会随parent取消的ctx，和go1.21的context.WithoutCancel一样
+func WithoutCancel(parent context.Context) context.Context {
+	if parent == nil {
+		panic("cannot create context from nil parent")
+	}
+	return withoutCancelCtx{parent: parent}
+}
+
+type withoutCancelCtx struct {
+	parent context.Context
+}
+
+func (withoutCancelCtx) Deadline() (deadline time.Time, ok bool) {
+	return
+}
+
+func (withoutCancelCtx) Done() <-chan struct{} {
+	return nil
+}
+
+func (withoutCancelCtx) Err() error {
+	return nil
+}
+
+func (c withoutCancelCtx) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+func (c withoutCancelCtx) String() string {
+	return "tools.WithoutCancel"
+}
@@ -0,0 +1,235 @@
+package go_cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/liyanbing/go-cache/errors"
+)
+
+/**
+ * 泛型版本的fetch
+ * 不管是从缓存中获取到的数据还是从fetcher中获取到的数据，返回的都是同一个静态类型T，调用方不再需要做类型判断
+ */
+
+// TypedFetcher 泛型版本的Fetcher
+type TypedFetcher[T any] func(ctx context.Context) (value T, expiration time.Duration, err error)
+
+// TypedCodec 负责T与缓存数据之间的相互转换
+type TypedCodec[T any] interface {
+	Encode(value T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+func Fetch[T any](ctx context.Context, cache Cache, key string, fetcher TypedFetcher[T], codec TypedCodec[T]) (T, error) {
+	var zero T
+	value, err := fetch(ctx, cache, key, func(ctx context.Context) (interface{}, time.Duration, error) {
+		value, expiration, err := fetcher(ctx)
+		return value, expiration, err
+	}, typedCodecName(codec), func(value interface{}) ([]byte, error) {
+		typed, ok := value.(T)
+		if !ok {
+			return nil, errors.ErrInvalidValue
+		}
+		return codec.Encode(typed)
+	}, func(data interface{}) (interface{}, error) {
+		byteData, ok := toBytes(data)
+		if !ok {
+			return nil, errors.ErrInvalidCacheValue
+		}
+		return codec.Decode(byteData)
+	})
+	if err != nil && !isStale(err) {
+		return zero, err
+	}
+	if value == nil {
+		return zero, err
+	}
+
+	ret, ok := value.(T)
+	if !ok {
+		return zero, errors.NewDecodeError(key, errors.ErrInvalidValue)
+	}
+	return ret, err
+}
+
+// Typed 把Bridge和值类型T绑定在一起，相当于Bridge的泛型方法
+type Typed[T any] struct {
+	bridge Bridge
+	codec  TypedCodec[T]
+}
+
+func NewTyped[T any](bridge Bridge, codec TypedCodec[T]) *Typed[T] {
+	return &Typed[T]{
+		bridge: bridge,
+		codec:  codec,
+	}
+}
+
+func (t *Typed[T]) Fetch(ctx context.Context, key string, fetcher TypedFetcher[T]) (T, error) {
+	return Fetch(ctx, t.bridge, key, fetcher, t.codec)
+}
+
+// TypedCodec实现了Name() string时作为写入元数据的codec名称
+func typedCodecName[T any](codec TypedCodec[T]) string {
+	if c, ok := codec.(interface{ Name() string }); ok {
+		return c.Name()
+	}
+	return ""
+}
+
+type jsonCodec[T any] struct{}
+
+// JsonCodec json编解码，T可以是结构体、结构体指针、slice、map等
+func JsonCodec[T any]() TypedCodec[T] {
+	return jsonCodec[T]{}
+}
+
+func (jsonCodec[T]) Name() string {
+	return CodecJson
+}
+
+func (jsonCodec[T]) Encode(value T) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSchema(reflect.TypeOf((*T)(nil)).Elem(), data), nil
+}
+
+func (jsonCodec[T]) Decode(data []byte) (T, error) {
+	var ret T
+	data, err := decodeSchema(reflect.TypeOf((*T)(nil)).Elem(), data)
+	if err != nil {
+		return ret, err
+	}
+	err = json.Unmarshal(data, &ret)
+	return ret, err
+}
+
+type protoCodec[M any, PM interface {
+	*M
+	proto.Message
+}] struct{}
+
+// ProtoCodec protobuf编解码，例如：ProtoCodec[pb.User]() 得到的是 TypedCodec[*pb.User]
+func ProtoCodec[M any, PM interface {
+	*M
+	proto.Message
+}]() TypedCodec[PM] {
+	return protoCodec[M, PM]{}
+}
+
+func (protoCodec[M, PM]) Name() string {
+	return CodecProtobuf
+}
+
+func (protoCodec[M, PM]) Encode(value PM) ([]byte, error) {
+	data, err := proto.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSchema(reflect.TypeOf((*M)(nil)).Elem(), data), nil
+}
+
+func (protoCodec[M, PM]) Decode(data []byte) (PM, error) {
+	data, err := decodeSchema(reflect.TypeOf((*M)(nil)).Elem(), data)
+	if err != nil {
+		return nil, err
+	}
+	ret := PM(new(M))
+	err = proto.Unmarshal(data, ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+type stringCodec struct{}
+
+func StringCodec() TypedCodec[string] {
+	return stringCodec{}
+}
+
+func (stringCodec) Name() string {
+	return CodecString
+}
+
+func (stringCodec) Encode(value string) ([]byte, error) {
+	return []byte(value), nil
+}
+
+func (stringCodec) Decode(data []byte) (string, error) {
+	return string(data), nil
+}
+
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64
+}
+
+type numberCodec[N Number] struct{}
+
+func NumberCodec[N Number]() TypedCodec[N] {
+	return numberCodec[N]{}
+}
+
+func (numberCodec[N]) Name() string {
+	return CodecNumber
+}
+
+func (numberCodec[N]) Encode(value N) ([]byte, error) {
+	return []byte(fmt.Sprintf("%v", value)), nil
+}
+
+// Decode 超出N的范围(例如300解码为int8、负数解码为uint)或者小数解码为整数时返回errors.ErrDecode
+func (numberCodec[N]) Decode(data []byte) (N, error) {
+	str := string(data)
+	if value, err := strconv.ParseInt(str, 10, 64); err == nil {
+		n := N(value)
+		if (n < 0) != (value < 0) || (!isFloat[N]() && int64(n) != value) {
+			return 0, numberOverflow[N](str)
+		}
+		return n, nil
+	}
+	if value, err := strconv.ParseUint(str, 10, 64); err == nil {
+		n := N(value)
+		if n < 0 || (!isFloat[N]() && uint64(n) != value) {
+			return 0, numberOverflow[N](str)
+		}
+		return n, nil
+	}
+
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, errors.ErrInvalidCacheValue
+	}
+	n := N(value)
+	if isFloat[N]() {
+		if math.IsInf(float64(n), 0) && !math.IsInf(value, 0) {
+			return 0, numberOverflow[N](str)
+		}
+		return n, nil
+	}
+	if float64(n) != value {
+		return 0, numberOverflow[N](str)
+	}
+	return n, nil
+}
+
+// N是否为浮点数
+func isFloat[N Number]() bool {
+	var one N = 1
+	return one/2 != 0
+}
+
+func numberOverflow[N Number](str string) error {
+	var n N
+	return errors.NewDecodeError("", fmt.Errorf("%v can not be represented by %T", str, n))
+}
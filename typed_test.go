@@ -0,0 +1,121 @@
+package go_cache
+
+import (
+	"context"
+	stdErrors "errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetch(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	cnt := int32(0)
+	fetchFunc := func(ctx context.Context) (*TempModel, time.Duration, error) {
+		atomic.AddInt32(&cnt, 1)
+		return &TempModel{
+			Name: "peter",
+			Age:  23,
+			Id:   123123,
+		}, time.Millisecond * 100, nil
+	}
+
+	// from fetcher
+	ret, err := Fetch(ctx, cache, "typed-json-key", fetchFunc, JsonCodec[*TempModel]())
+	ast.Nil(err)
+	ast.Equal("peter", ret.Name)
+	ast.EqualValues(23, ret.Age)
+	ast.EqualValues(123123, ret.Id)
+	ast.EqualValues(1, atomic.LoadInt32(&cnt))
+
+	// from cache
+	for i := 0; i < 10; i++ {
+		ret, err = Fetch(ctx, cache, "typed-json-key", fetchFunc, JsonCodec[*TempModel]())
+		ast.Nil(err)
+		ast.Equal("peter", ret.Name)
+		ast.EqualValues(23, ret.Age)
+		ast.EqualValues(123123, ret.Id)
+		ast.EqualValues(1, atomic.LoadInt32(&cnt))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	ret, err = Fetch(ctx, cache, "typed-json-key", fetchFunc, JsonCodec[*TempModel]())
+	ast.Nil(err)
+	ast.Equal("peter", ret.Name)
+	ast.EqualValues(2, atomic.LoadInt32(&cnt))
+}
+
+func TestFetchWithTypedCodecs(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		str, err := Fetch(ctx, cache, "typed-string-key", func(ctx context.Context) (string, time.Duration, error) {
+			return "abc", time.Millisecond * 100, nil
+		}, StringCodec())
+		ast.Nil(err)
+		ast.Equal("abc", str)
+
+		num, err := Fetch(ctx, cache, "typed-number-key", func(ctx context.Context) (int64, time.Duration, error) {
+			return 9887, time.Millisecond * 100, nil
+		}, NumberCodec[int64]())
+		ast.Nil(err)
+		ast.EqualValues(9887, num)
+
+		pb, err := Fetch(ctx, cache, "typed-proto-key", func(ctx context.Context) (*TempModelPb, time.Duration, error) {
+			return &TempModelPb{IsMember: true, ExpireAt: 101}, time.Millisecond * 100, nil
+		}, ProtoCodec[TempModelPb]())
+		ast.Nil(err)
+		ast.True(pb.IsMember)
+		ast.EqualValues(101, pb.ExpireAt)
+
+		arr, err := Fetch(ctx, cache, "typed-array-key", func(ctx context.Context) ([]TempModel, time.Duration, error) {
+			return []TempModel{{Name: "peter"}, {Name: "tome"}}, time.Millisecond * 100, nil
+		}, JsonCodec[[]TempModel]())
+		ast.Nil(err)
+		ast.Equal(2, len(arr))
+		ast.Equal("tome", arr[1].Name)
+
+		m, err := Fetch(ctx, cache, "typed-map-key", func(ctx context.Context) (map[string]int, time.Duration, error) {
+			return map[string]int{"a": 1, "b": 2}, time.Millisecond * 100, nil
+		}, JsonCodec[map[string]int]())
+		ast.Nil(err)
+		ast.Equal(map[string]int{"a": 1, "b": 2}, m)
+	}
+}
+
+func TestNumberCodec_Decode(t *testing.T) {
+	ast := assert.New(t)
+
+	v8, err := NumberCodec[int8]().Decode([]byte("-128"))
+	ast.Nil(err)
+	ast.EqualValues(-128, v8)
+	v64, err := NumberCodec[uint64]().Decode([]byte("18446744073709551615"))
+	ast.Nil(err)
+	ast.EqualValues(uint64(18446744073709551615), v64)
+	f32, err := NumberCodec[float32]().Decode([]byte("1.5"))
+	ast.Nil(err)
+	ast.EqualValues(1.5, f32)
+	i, err := NumberCodec[int]().Decode([]byte("3e2"))
+	ast.Nil(err)
+	ast.EqualValues(300, i)
+
+	// 超出范围或者有小数部分
+	_, err = NumberCodec[int8]().Decode([]byte("300"))
+	ast.True(stdErrors.Is(err, errors.ErrDecode))
+	_, err = NumberCodec[uint]().Decode([]byte("-1"))
+	ast.True(stdErrors.Is(err, errors.ErrDecode))
+	_, err = NumberCodec[int64]().Decode([]byte("18446744073709551615"))
+	ast.True(stdErrors.Is(err, errors.ErrDecode))
+	_, err = NumberCodec[int]().Decode([]byte("1.5"))
+	ast.True(stdErrors.Is(err, errors.ErrDecode))
+	_, err = NumberCodec[float32]().Decode([]byte("1e300"))
+	ast.True(stdErrors.Is(err, errors.ErrDecode))
+	_, err = NumberCodec[int]().Decode([]byte("abc"))
+	ast.Equal(errors.ErrInvalidCacheValue, err)
+}
@@ -2,51 +2,348 @@ package go_cache
 
 import (
 	"context"
-	"log"
+	stdErrors "errors"
+	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/liyanbing/go-cache/errors"
+	"github.com/liyanbing/go-cache/logger"
+	"github.com/liyanbing/go-cache/tools"
 )
 
+// fetch的配置，Bridge上的配置会被context中的配置覆盖
+type fetchConfig struct {
+	flight               *flight
+	staleWhileRevalidate time.Duration
+	earlyExpiration      float64 // XFetch的beta，0表示不提前刷新
+	staleIfError         time.Duration
+	fillLock             *fillLock
+	locker               Locker
+	policy               Policy
+	maxStale             time.Duration
+	fallback             *fallback
+	observer             Observer
+	logger               logger.Logger
+	compression          *compression
+	encryption           *encryption
+	envelope             bool   // 总是在写入的数据中附带元数据
+	codec                string // 本次调用使用的codec名称，写入元数据
+}
+
+func newFetchConfig(ctx context.Context, cache Cache) fetchConfig {
+	var cfg fetchConfig
+	if c, ok := cache.(interface{ fetchConfig() fetchConfig }); ok {
+		cfg = c.fetchConfig()
+	}
+	if cfg.flight == nil {
+		cfg.flight = flightOf(cache)
+	}
+	if cfg.locker == nil {
+		cfg.locker, _ = findCache[Locker](cache)
+	}
+	if cfg.observer == nil {
+		cfg.observer = NopObserver{}
+	}
+	if cfg.logger == nil {
+		cfg.logger = logger.Default()
+	}
+
+	if staleTTL, ok := staleWhileRevalidate(ctx); ok {
+		cfg.staleWhileRevalidate = staleTTL
+	}
+	if beta, ok := earlyExpiration(ctx); ok {
+		cfg.earlyExpiration = beta
+	}
+	if staleTTL, ok := staleIfError(ctx); ok {
+		cfg.staleIfError = staleTTL
+	}
+	cfg.policy = policyFromContext(ctx)
+	cfg.maxStale = maxStale(ctx)
+	return cfg
+}
+
+// 过期之后仍然可以直接返回旧数据的时间
+func (c fetchConfig) staleWindow() time.Duration {
+	if c.maxStale > c.staleWhileRevalidate {
+		return c.maxStale
+	}
+	return c.staleWhileRevalidate
+}
+
+// 数据已经过期，并且超过了WithMaxStale可以接受的时间；不会刷新的批量获取当作缓存中不存在
+func (c fetchConfig) tooStale(it *item, now time.Time) bool {
+	return it.expired(now) && it.staleFor(now) > c.maxStale
+}
+
+// 是否需要在写入的数据中附带元数据
+func (c fetchConfig) withItem() bool {
+	return c.envelope || c.staleWhileRevalidate > 0 || c.earlyExpiration > 0 || c.staleIfError > 0
+}
+
+// 写入缓存的元数据，开启了WithEnvelope时带有codec、写入时间和类型等信息
+func (c fetchConfig) newItem(value interface{}, now time.Time) *item {
+	if !c.envelope {
+		return &item{}
+	}
+	it := &item{
+		createdAt: now.UnixNano(),
+		codec:     c.codec,
+	}
+	if value != nil {
+		it.typeName = fmt.Sprintf("%T", value)
+	}
+	return it
+}
+
+// 返回写入缓存的数据和过期时间，value为fetcher返回的数据，cacheData为编码之后的数据，delta为调用fetcher花费的时间
+func (c fetchConfig) cacheValue(value interface{}, cacheData []byte, expires, delta time.Duration) (interface{}, time.Duration, error) {
+	compressor, cacheData, err := c.encodePayload(cacheData)
+	if err != nil {
+		return nil, 0, err
+	}
+	// 压缩过的数据需要在元数据中记录压缩算法
+	if compressor == compressNone && (!c.withItem() || (expires <= 0 && !c.envelope)) {
+		return cacheData, expires, nil
+	}
+
+	now := time.Now()
+	it := c.newItem(value, now)
+	it.payload = cacheData
+	it.compressor = compressor
+	if expires <= 0 || !c.withItem() {
+		return it.marshal(), expires, nil
+	}
+
+	it.expireAt = now.Add(expires).UnixNano()
+	it.delta = int64(delta)
+	// 过期之后的数据还需要保留一段时间
+	return it.marshal(), expires + c.staleWhileRevalidate + c.staleIfError, nil
+}
+
 func fetch(
 	ctx context.Context,
 	cache Cache,
 	key string,
-	fetcher Fetcher,
+	fetcher ContextFetcher,
+	codec string,
 	e encoder,
 	d Decoder) (interface{}, error) {
 
+	cfg := newFetchConfig(ctx, cache)
+	cfg.codec = codec
+	namespace := namespaceOf(cache)
+	groupKey := cfg.flight.key(cache, key)
+	if !cfg.policy.canWrite() {
+		// 不写缓存的调用不能和写缓存的调用合并
+		groupKey += "|readonly"
+	}
+
 	do := func() (interface{}, error) {
-		return single.Do(key, func() (interface{}, error) {
-			value, expires, err := fetcher()
-			if err != nil {
-				return nil, err
-			}
+		return cfg.do(ctx, namespace, key, groupKey, func(ctx context.Context) (interface{}, error) {
+			return fetchAndSet(ctx, cache, key, fetcher, e, cfg)
+		})
+	}
+
+	if !cfg.policy.canRead() {
+		return do()
+	}
+
+	// 缓存中没有可用的数据时，通过跨进程的填充锁保证只有一个进程调用fetcher
+	fill := func() (interface{}, error) {
+		if !cfg.policy.canFetch() {
+			return nil, errors.ErrEmptyCache
+		}
+		return cfg.do(ctx, namespace, key, groupKey, func(ctx context.Context) (interface{}, error) {
+			return fetchWithLock(ctx, cache, key, fetcher, e, d, cfg)
+		})
+	}
+
+	cacheData, err := cache.Get(ctx, key)
+	if err != nil && err != errors.ErrEmptyCache {
+		return fetchFallback(ctx, cache, key, groupKey, fetcher, e, cfg, errors.NewBackendError("", key, err))
+	}
 
-			cacheData, err := e(value)
-			if err != nil {
+	if err == errors.ErrEmptyCache {
+		cfg.observer.OnMiss(ctx, namespace, key)
+		return fill()
+	}
+
+	it := newItem(cacheData)
+	if it.notFound {
+		cfg.observer.OnHit(ctx, namespace, key)
+		return nil, errors.ErrNotFound
+	}
+
+	now := time.Now()
+	if it.expired(now) && it.staleFor(now) > cfg.staleWindow() {
+		cfg.observer.OnMiss(ctx, namespace, key)
+		// 需要同步刷新，刷新失败时在stale-if-error的时间内返回旧数据
+		value, err := fill()
+		if err != nil && err != errors.ErrEmptyCache && !stdErrors.Is(err, errors.ErrNotFound) &&
+			cfg.staleIfError > 0 && it.staleFor(now) <= cfg.staleWindow()+cfg.staleIfError {
+			staleValue, decodeErr := cfg.decode(d, it)
+			if decodeErr != nil {
+				cfg.observer.OnDecodeError(ctx, namespace, key, errors.NewDecodeError(key, decodeErr))
 				return nil, err
 			}
+			return staleValue, &errors.StaleError{Err: err}
+		}
+		return value, err
+	}
 
-			err = cache.Set(ctx, key, cacheData, expires)
-			if err != nil {
-				log.Printf("set bridger <%v,%v> Err:%v", key, value, err)
-				err = nil
-			}
-			return value, nil
+	value, err := cfg.decode(d, it)
+	if isSchemaNewer(err) {
+		// 新版本的进程写入的数据，调用fetcher但是不覆盖
+		cfg.observer.OnMiss(ctx, namespace, key)
+		if !cfg.policy.canFetch() {
+			return nil, errors.ErrEmptyCache
+		}
+		readOnly, readOnlyKey := cfg.readOnly(), groupKey
+		if cfg.policy.canWrite() {
+			readOnlyKey += "|readonly"
+		}
+		return readOnly.do(ctx, namespace, key, readOnlyKey, func(ctx context.Context) (interface{}, error) {
+			return fetchAndSet(ctx, cache, key, fetcher, e, readOnly)
 		})
 	}
+	if isSchemaMismatch(err) {
+		// 旧版本的数据不能升级，当作缓存中不存在
+		cfg.observer.OnMiss(ctx, namespace, key)
+		return fill()
+	}
+	if err != nil {
+		err = errors.NewDecodeError(key, err)
+		cfg.observer.OnDecodeError(ctx, namespace, key, err)
+		return nil, err
+	}
+	cfg.observer.OnHit(ctx, namespace, key)
 
-	if noUseCache(ctx) {
-		return do()
+	// 只读缓存或者不写缓存时不刷新
+	if !cfg.policy.canFetch() || !cfg.policy.canWrite() {
+		return value, nil
 	}
 
-	cacheData, err := cache.Get(ctx, key)
-	if err != nil && err != errors.ErrEmptyCache {
+	// 提前刷新，刷新失败时返回还没有过期的数据
+	if !it.expired(now) && it.expireEarly(now, cfg.earlyExpiration) {
+		newValue, err := do()
+		if err != nil {
+			return value, nil
+		}
+		return newValue, nil
+	}
+
+	// 数据已经过期，先返回旧数据，同时在后台刷新(同一个key只会有一个刷新)
+	if it.expired(now) {
+		if _, loaded := cfg.flight.refreshing.LoadOrStore(groupKey, struct{}{}); !loaded {
+			refreshCtx := tools.WithoutCancel(ctx)
+			go func() {
+				defer cfg.flight.refreshing.Delete(groupKey)
+				_, _ = cfg.flight.group.Do(refreshCtx, groupKey, func(ctx context.Context) (interface{}, error) {
+					return fetchAndSet(ctx, cache, key, fetcher, e, cfg)
+				})
+			}()
+		}
+	}
+	return value, nil
+}
+
+func fetchAndSet(
+	ctx context.Context,
+	cache Cache,
+	key string,
+	fetcher ContextFetcher,
+	e encoder,
+	cfg fetchConfig) (interface{}, error) {
+
+	start := time.Now()
+	value, expires, err := fetcher(ctx)
+	cfg.observer.OnFetch(ctx, namespaceOf(cache), key, time.Since(start), err)
+	if stdErrors.Is(err, errors.ErrNotFound) {
+		// 缓存"不存在"，防止缓存穿透
+		if expires > 0 && cfg.policy.canWrite() {
+			it := cfg.newItem(nil, time.Now())
+			it.notFound = true
+			data := it.marshal()
+			setErr := cache.Set(ctx, key, data, expires)
+			if setErr == nil {
+				setErr = tagWritten(ctx, cache, expires, key)
+			}
+			if setErr != nil {
+				cfg.setFailed(ctx, namespaceOf(cache), key, setErr)
+				cfg.logger.Warn("set not found failed", "key", key, "err", setErr)
+			} else {
+				cfg.observer.OnSet(ctx, namespaceOf(cache), key, len(data))
+			}
+		}
 		return nil, err
 	}
+	if err != nil {
+		return nil, errors.NewFetcherError(key, err)
+	}
 
-	if err == errors.ErrEmptyCache {
-		return do()
+	if !cfg.policy.canWrite() {
+		return value, nil
+	}
+
+	cacheData, err := e(value)
+	if err != nil {
+		return nil, errors.NewEncodeError(key, err)
+	}
+	data, expires, err := cfg.cacheValue(value, cacheData, expires, time.Since(start))
+	if err != nil {
+		return nil, errors.NewEncodeError(key, err)
+	}
+
+	err = cache.Set(ctx, key, data, expires)
+	if err == nil {
+		err = tagWritten(ctx, cache, expires, key)
+	}
+	if err != nil {
+		cfg.setFailed(ctx, namespaceOf(cache), key, err)
+		cfg.logger.Warn("set cache failed", "key", key, "err", err)
+	} else {
+		cfg.observer.OnSet(ctx, namespaceOf(cache), key, dataSize(data))
 	}
-	return d(cacheData)
+	return value, nil
+}
+
+// 不写缓存的配置
+func (c fetchConfig) readOnly() fetchConfig {
+	c.policy = PolicyReadOnly
+	return c
+}
+
+// 写缓存失败时通知Observer和降级的回调
+func (c fetchConfig) setFailed(ctx context.Context, namespace, key string, err error) {
+	err = errors.NewBackendError("", key, err)
+	c.observer.OnSetError(ctx, namespace, key, err)
+	c.reportBackendError(ctx, key, err)
+}
+
+// 调用方的ctx超时或者被取消时返回的ctx.Err()包装成ErrTimeout
+func timeoutError(ctx context.Context, key string, err error) error {
+	if err != nil && err == ctx.Err() {
+		return errors.NewTimeoutError(key, err)
+	}
+	return err
+}
+
+// 通过group合并相同key的调用，fn没有被执行说明复用了其他调用的结果
+func (c fetchConfig) do(ctx context.Context, namespace, key, groupKey string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	var executed int32
+	value, err := c.flight.group.Do(ctx, groupKey, func(ctx context.Context) (interface{}, error) {
+		atomic.StoreInt32(&executed, 1)
+		return fn(ctx)
+	})
+	if atomic.LoadInt32(&executed) == 0 && ctx.Err() == nil {
+		c.observer.OnCoalesced(ctx, namespace, key)
+	}
+	return value, timeoutError(ctx, key, err)
+}
+
+// 写入缓存的字节数
+func dataSize(data interface{}) int {
+	byteData, _ := toBytes(data)
+	return len(byteData)
 }
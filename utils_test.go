@@ -0,0 +1,267 @@
+package go_cache
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchStaleWhileRevalidate(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	bridge := MustNewBridge(WithLRU(10), WithStaleWhileRevalidate(time.Second))
+
+	cnt := int32(0)
+	fetchFunc := func() (interface{}, time.Duration, error) {
+		n := atomic.AddInt32(&cnt, 1)
+		time.Sleep(time.Millisecond * 5)
+		return n, time.Millisecond * 50, nil
+	}
+
+	ret, err := bridge.FetchWithNumber(ctx, "swr-key", fetchFunc)
+	ast.Nil(err)
+	ast.EqualValues(1, ret)
+
+	// 软过期之后立即返回旧数据，只会有一个后台刷新
+	time.Sleep(time.Millisecond * 60)
+	for i := 0; i < 10; i++ {
+		ret, err = bridge.FetchWithNumber(ctx, "swr-key", fetchFunc)
+		ast.Nil(err)
+		ast.EqualValues(1, ret)
+	}
+
+	time.Sleep(time.Millisecond * 20)
+	ast.EqualValues(2, atomic.LoadInt32(&cnt))
+	ret, err = bridge.FetchWithNumber(ctx, "swr-key", fetchFunc)
+	ast.Nil(err)
+	ast.EqualValues(2, ret)
+
+	// 包装之后的数据对FetchWithKeys透明
+	values, err := bridge.FetchWithKeys(ctx, "swr-key")
+	ast.Nil(err)
+	ast.Equal([]interface{}{[]byte("2")}, values)
+}
+
+func TestFetchStaleWhileRevalidateContext(t *testing.T) {
+	ast := assert.New(t)
+	ctx := ContextWithStaleWhileRevalidate(context.Background(), time.Second)
+	cache := MustNewBridge(WithLRU(10))
+
+	cnt := int32(0)
+	fetchFunc := func() (interface{}, time.Duration, error) {
+		atomic.AddInt32(&cnt, 1)
+		return "abc", time.Millisecond * 50, nil
+	}
+
+	ret, err := FetchWithString(ctx, cache, "swr-string-key", fetchFunc)
+	ast.Nil(err)
+	ast.Equal("abc", ret)
+
+	time.Sleep(time.Millisecond * 60)
+	ret, err = FetchWithString(ctx, cache, "swr-string-key", fetchFunc)
+	ast.Nil(err)
+	ast.Equal("abc", ret)
+
+	time.Sleep(time.Millisecond * 10)
+	ast.EqualValues(2, atomic.LoadInt32(&cnt))
+}
+
+func TestFetchNotFound(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	bridge := MustNewBridge(WithLRU(10))
+
+	cnt := int32(0)
+	fetchFunc := func() (interface{}, time.Duration, error) {
+		atomic.AddInt32(&cnt, 1)
+		return nil, time.Millisecond * 50, fmt.Errorf("user 1: %w", errors.ErrNotFound)
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err := bridge.FetchWithJson(ctx, "not-found-key", fetchFunc, TempModel{})
+		ast.True(stdErrors.Is(err, errors.ErrNotFound))
+		ast.EqualValues(1, atomic.LoadInt32(&cnt))
+
+		_, err = Fetch(ctx, bridge, "not-found-key", func(ctx context.Context) (*TempModel, time.Duration, error) {
+			return nil, time.Millisecond * 50, errors.ErrNotFound
+		}, JsonCodec[*TempModel]())
+		ast.Equal(errors.ErrNotFound, err)
+	}
+
+	// 缓存了"不存在"的key通过empty返回
+	var empty []string
+	outputs := 0
+	err := bridge.FetchWithIncludeKeys(ctx, func(value interface{}) error {
+		outputs++
+		return nil
+	}, func(outerKey string) {
+		empty = append(empty, outerKey)
+	}, JsonDecode(TempModel{}), "not-found-key", "other-key")
+	ast.Nil(err)
+	ast.Equal([]string{"not-found-key", "other-key"}, empty)
+	ast.Equal(0, outputs)
+
+	time.Sleep(time.Millisecond * 60)
+	_, err = bridge.FetchWithJson(ctx, "not-found-key", fetchFunc, TempModel{})
+	ast.True(stdErrors.Is(err, errors.ErrNotFound))
+	ast.EqualValues(2, atomic.LoadInt32(&cnt))
+}
+
+func TestFetchEarlyExpiration(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	bridge := MustNewBridge(WithLRU(10), WithEarlyExpiration(1e9))
+
+	cnt := int32(0)
+	fetchFunc := func() (interface{}, time.Duration, error) {
+		n := atomic.AddInt32(&cnt, 1)
+		time.Sleep(time.Millisecond * 10)
+		return n, time.Second, nil
+	}
+
+	ret, err := bridge.FetchWithNumber(ctx, "xfetch-key", fetchFunc)
+	ast.Nil(err)
+	ast.EqualValues(1, ret)
+
+	// 关闭之后不会提前刷新
+	ret, err = bridge.FetchWithNumber(ContextWithEarlyExpiration(ctx, 0), "xfetch-key", fetchFunc)
+	ast.Nil(err)
+	ast.EqualValues(1, ret)
+	ast.EqualValues(1, atomic.LoadInt32(&cnt))
+
+	// fetcher花费的时间*beta远大于剩余的过期时间，一定会提前刷新
+	ret, err = bridge.FetchWithNumber(ctx, "xfetch-key", fetchFunc)
+	ast.Nil(err)
+	ast.EqualValues(2, ret)
+	ast.EqualValues(2, atomic.LoadInt32(&cnt))
+
+	// 刷新失败时返回还没有过期的数据
+	ret, err = bridge.FetchWithNumber(ctx, "xfetch-key", func() (interface{}, time.Duration, error) {
+		time.Sleep(time.Millisecond * 10)
+		return nil, 0, fmt.Errorf("db error")
+	})
+	ast.Nil(err)
+	ast.EqualValues(2, ret)
+}
+
+func TestFetchStaleIfError(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	bridge := MustNewBridge(WithLRU(10), WithStaleIfError(time.Millisecond*100))
+
+	ret, err := bridge.FetchWithString(ctx, "sie-key", func() (interface{}, time.Duration, error) {
+		return "abc", time.Millisecond * 50, nil
+	})
+	ast.Nil(err)
+	ast.Equal("abc", ret)
+
+	// 过期之后刷新失败，返回旧数据
+	time.Sleep(time.Millisecond * 60)
+	failFunc := func() (interface{}, time.Duration, error) {
+		return nil, 0, fmt.Errorf("db error")
+	}
+	ret, err = bridge.FetchWithString(ctx, "sie-key", failFunc)
+	ast.True(stdErrors.Is(err, errors.ErrStale))
+	ast.True(stdErrors.Is(err, errors.ErrFetcher))
+	ast.Equal("db error", stdErrors.Unwrap(stdErrors.Unwrap(err)).Error())
+	ast.Equal("abc", ret)
+
+	typed, err := Fetch(ctx, bridge, "sie-key", func(ctx context.Context) (string, time.Duration, error) {
+		return "", 0, fmt.Errorf("db error")
+	}, StringCodec())
+	ast.True(stdErrors.Is(err, errors.ErrStale))
+	ast.Equal("abc", typed)
+
+	// 超过stale-if-error的时间之后直接返回错误
+	time.Sleep(time.Millisecond * 100)
+	_, err = bridge.FetchWithString(ctx, "sie-key", failFunc)
+	ast.False(stdErrors.Is(err, errors.ErrStale))
+	ast.NotNil(err)
+}
+
+func TestFetchWithKeys_Expired(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	bridge := MustNewBridge(WithLRU(10), WithStaleIfError(time.Hour))
+
+	_, err := bridge.FetchWithString(ctx, "expired-key", func() (interface{}, time.Duration, error) {
+		return "abc", time.Millisecond * 50, nil
+	})
+	ast.Nil(err)
+	time.Sleep(time.Millisecond * 100)
+
+	// 过期之后仍然保留在缓存中的数据当作不存在
+	values, err := bridge.FetchWithKeys(ctx, "expired-key")
+	ast.Nil(err)
+	ast.Equal([]interface{}{nil}, values)
+
+	var empty []string
+	err = bridge.FetchWithIncludeKeys(ctx, func(value interface{}) error {
+		t.Fatal("expired value returned")
+		return nil
+	}, func(outerKey string) {
+		empty = append(empty, outerKey)
+	}, func(value interface{}) (interface{}, error) {
+		return value, nil
+	}, "expired-key")
+	ast.Nil(err)
+	ast.Equal([]string{"expired-key"}, empty)
+
+	// WithMaxStale可以接受的旧数据
+	staleCtx := WithMaxStale(ctx, time.Minute)
+	values, err = bridge.FetchWithKeys(staleCtx, "expired-key")
+	ast.Nil(err)
+	ast.Equal([]interface{}{[]byte("abc")}, values)
+	outputs := 0
+	err = bridge.FetchWithIncludeKeys(staleCtx, func(value interface{}) error {
+		outputs++
+		return nil
+	}, func(outerKey string) {
+		t.Fatal("stale value not returned")
+	}, func(value interface{}) (interface{}, error) {
+		return value, nil
+	}, "expired-key")
+	ast.Nil(err)
+	ast.Equal(1, outputs)
+}
+
+func TestFetchErrors(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	bridge := MustNewBridge(WithLRU(10))
+
+	// 缓存中的数据无法解析
+	err := bridge.Set(ctx, "err-key", []byte("{invalid"), time.Second)
+	ast.Nil(err)
+	_, err = bridge.FetchWithJson(ctx, "err-key", func() (interface{}, time.Duration, error) {
+		return &TempModel{}, time.Second, nil
+	}, &TempModel{})
+	ast.True(stdErrors.Is(err, errors.ErrDecode))
+
+	var cacheErr *errors.Error
+	ast.True(stdErrors.As(err, &cacheErr))
+	ast.Equal("err-key", cacheErr.Key)
+
+	// fetcher的错误
+	dbErr := fmt.Errorf("db error")
+	_, err = bridge.FetchWithString(ctx, "err-key2", func() (interface{}, time.Duration, error) {
+		return nil, 0, dbErr
+	})
+	ast.True(stdErrors.Is(err, errors.ErrFetcher))
+	ast.True(stdErrors.Is(err, dbErr))
+	ast.True(stdErrors.As(err, &cacheErr))
+	ast.Equal("err-key2", cacheErr.Key)
+
+	// 无法编码的数据
+	_, err = bridge.FetchWithProtobuf(ctx, "err-key3", func() (interface{}, time.Duration, error) {
+		return "abc", time.Second, nil
+	}, &TempModelPb{})
+	ast.True(stdErrors.Is(err, errors.ErrEncode))
+	ast.True(stdErrors.Is(err, errors.ErrInvalidValue))
+}
@@ -0,0 +1,257 @@
+package go_cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/liyanbing/go-cache/logger"
+)
+
+/**
+ * namespace的版本号(generation)
+ * 1、缓存后端为每个namespace保存一个版本号(redis为namespace:__gocache__:version，lru、memory只在进程内有效)，版本号会加到每个key的前面
+ * 2、IncrementNamespaceVersion增加版本号之后，旧版本的key都不会再被读到，不需要扫描redis删除，旧数据等待过期即可
+ * 3、版本号在进程内缓存refreshInterval，其他进程增加版本号之后，最多refreshInterval之后才会使用新的版本号
+ */
+
+const defaultVersionRefreshInterval = time.Second
+
+// NamespaceVersioner 由支持namespace版本号的Cache实现
+type NamespaceVersioner interface {
+	// NamespaceVersion 返回namespace当前的版本号，没有增加过时为0
+	NamespaceVersion(ctx context.Context) (int64, error)
+	// IncrNamespaceVersion 增加namespace的版本号，返回增加之后的版本号
+	IncrNamespaceVersion(ctx context.Context) (int64, error)
+}
+
+// 把版本号加到key的前面，版本号为0时key不变，和开启版本号之前写入的数据兼容
+type versionedCache struct {
+	// 通过atomic读写，放在最前面保证32位平台上8字节对齐
+	version  int64
+	loadedAt int64 // 读取版本号的时间(unix nano)，0表示还没有读取
+
+	Cache
+	versioner       NamespaceVersioner
+	refreshInterval time.Duration
+	logger          logger.Logger
+
+	mu sync.Mutex // 同一时间只有一个goroutine读取版本号
+}
+
+func newVersionedCache(cache Cache, refreshInterval time.Duration, l logger.Logger) (*versionedCache, error) {
+	versioner, ok := findCache[NamespaceVersioner](cache)
+	if !ok {
+		return nil, fmt.Errorf("%w: cache does not support namespace version", errors.ErrInvalidOption)
+	}
+	if refreshInterval <= 0 {
+		refreshInterval = defaultVersionRefreshInterval
+	}
+	return &versionedCache{
+		Cache:           cache,
+		versioner:       versioner,
+		refreshInterval: refreshInterval,
+		logger:          l,
+	}, nil
+}
+
+func (c *versionedCache) Unwrap() Cache {
+	return c.Cache
+}
+
+// SetNamespace 版本号属于namespace，修改namespace之后重新读取
+func (c *versionedCache) SetNamespace(namespace string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Cache.SetNamespace(namespace)
+	atomic.StoreInt64(&c.version, 0)
+	atomic.StoreInt64(&c.loadedAt, 0)
+}
+
+func (c *versionedCache) fresh(loadedAt int64) bool {
+	return loadedAt != 0 && time.Since(time.Unix(0, loadedAt)) < c.refreshInterval
+}
+
+// 返回当前的版本号，超过refreshInterval时重新读取
+func (c *versionedCache) current(ctx context.Context) (int64, error) {
+	loadedAt := atomic.LoadInt64(&c.loadedAt)
+	if c.fresh(loadedAt) {
+		return atomic.LoadInt64(&c.version), nil
+	}
+
+	if loadedAt == 0 {
+		c.mu.Lock()
+	} else if !c.mu.TryLock() {
+		// 其他goroutine正在刷新，先使用旧的版本号
+		return atomic.LoadInt64(&c.version), nil
+	}
+	defer c.mu.Unlock()
+
+	loadedAt = atomic.LoadInt64(&c.loadedAt)
+	if c.fresh(loadedAt) {
+		return atomic.LoadInt64(&c.version), nil
+	}
+
+	version, err := c.versioner.NamespaceVersion(ctx)
+	if err != nil {
+		if loadedAt == 0 {
+			return 0, err
+		}
+		// 刷新失败时继续使用旧的版本号，refreshInterval之后再重试
+		c.logger.Warn("refresh namespace version failed", "namespace", namespaceOf(c.Cache), "err", err)
+		atomic.StoreInt64(&c.loadedAt, time.Now().UnixNano())
+		return atomic.LoadInt64(&c.version), nil
+	}
+	c.store(version)
+	return atomic.LoadInt64(&c.version), nil
+}
+
+// 版本号只会增加，redis从库延迟等原因读到的旧版本号会被忽略
+func (c *versionedCache) store(version int64) {
+	for {
+		old := atomic.LoadInt64(&c.version)
+		if version <= old || atomic.CompareAndSwapInt64(&c.version, old, version) {
+			break
+		}
+	}
+	atomic.StoreInt64(&c.loadedAt, time.Now().UnixNano())
+}
+
+func (c *versionedCache) incr(ctx context.Context) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	version, err := c.versioner.IncrNamespaceVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+	c.store(version)
+	return version, nil
+}
+
+// 版本号的前缀为__v<版本号>__:，业务的key不应该以__开头，否则可能和其他版本的key冲突
+func versionPrefix(version int64) string {
+	if version == 0 {
+		return ""
+	}
+	return fmt.Sprintf("__v%d__:", version)
+}
+
+func (c *versionedCache) prefix(ctx context.Context) (string, error) {
+	version, err := c.current(ctx)
+	if err != nil {
+		return "", err
+	}
+	return versionPrefix(version), nil
+}
+
+func addPrefix(prefix string, keys []string) []string {
+	newKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		newKeys = append(newKeys, prefix+key)
+	}
+	return newKeys
+}
+
+func (c *versionedCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	prefix, err := c.prefix(ctx)
+	if err != nil {
+		return err
+	}
+	return c.Cache.Set(ctx, prefix+key, value, expiration)
+}
+
+func (c *versionedCache) Get(ctx context.Context, key string) (interface{}, error) {
+	prefix, err := c.prefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.Cache.Get(ctx, prefix+key)
+}
+
+func (c *versionedCache) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	prefix, err := c.prefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.Cache.MGet(ctx, addPrefix(prefix, keys)...)
+}
+
+func (c *versionedCache) MSet(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	prefix, err := c.prefix(ctx)
+	if err != nil {
+		return err
+	}
+
+	newValues := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		newValues[prefix+key] = value
+	}
+	return mset(ctx, c.Cache, newValues, expiration)
+}
+
+func (c *versionedCache) Remove(ctx context.Context, key ...string) error {
+	prefix, err := c.prefix(ctx)
+	if err != nil {
+		return err
+	}
+	return c.Cache.Remove(ctx, addPrefix(prefix, key)...)
+}
+
+// Tag tag中记录的是加上版本号之后的key，增加版本号之后旧的tag不会再被删除，等待过期即可
+func (c *versionedCache) Tag(ctx context.Context, tags []string, expiration time.Duration, keys ...string) error {
+	tagger, err := taggerOf(c.Cache)
+	if err != nil {
+		return err
+	}
+	prefix, err := c.prefix(ctx)
+	if err != nil {
+		return err
+	}
+	return tagger.Tag(ctx, tags, expiration, addPrefix(prefix, keys)...)
+}
+
+func (c *versionedCache) InvalidateTags(ctx context.Context, tags ...string) ([]string, error) {
+	tagger, err := taggerOf(c.Cache)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := c.prefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := tagger.InvalidateTags(ctx, tags...)
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, prefix)
+	}
+	return keys, err
+}
+
+// 通知其他进程时使用加上版本号之后的key
+func (c *versionedCache) mapKeys(ctx context.Context, keys []string) ([]string, error) {
+	prefix, err := c.prefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return addPrefix(prefix, keys), nil
+}
+
+// IncrementNamespaceVersion 增加namespace的版本号，之前写入的key都不会再被读到；开启了WithInvalidation时通知其他进程清空进程内的缓存，
+// 其他进程最多在版本号的refreshInterval之后使用新的版本号
+func (c *bridger) IncrementNamespaceVersion(ctx context.Context) (int64, error) {
+	if c.versioned == nil {
+		return 0, fmt.Errorf("%w: namespace version is not enabled", errors.ErrNotSupported)
+	}
+
+	version, err := c.versioned.incr(ctx)
+	if err != nil || c.bus == nil {
+		return version, err
+	}
+	return version, c.bus.PublishFlush(ctx, namespaceOf(c.Cache))
+}
@@ -0,0 +1,129 @@
+package go_cache
+
+import (
+	"context"
+	stdErrors "errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/liyanbing/go-cache/cacher/lru"
+	"github.com/liyanbing/go-cache/errors"
+	"github.com/stretchr/testify/assert"
+
+	redis "github.com/go-redis/redis/v8"
+	redisCache "github.com/liyanbing/go-cache/cacher/redis"
+)
+
+func TestBridgeNamespaceVersion(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	_, err := NewBridge(WithCache(&untaggedCache{Cache: lru.NewLRU(10)}), WithNamespaceVersion(time.Second))
+	ast.True(stdErrors.Is(err, errors.ErrInvalidOption))
+
+	_, err = MustNewBridge(WithLRU(10)).IncrementNamespaceVersion(ctx)
+	ast.True(stdErrors.Is(err, errors.ErrNotSupported))
+
+	cache := lru.NewLRU(10)
+	cache.SetNamespace("version")
+	ast.Nil(cache.Set(ctx, "old", "value", time.Minute))
+
+	bridge := MustNewBridge(WithCache(cache), WithNamespaceVersion(time.Minute))
+	// 版本号为0时可以读到开启版本号之前的数据
+	value, err := bridge.Get(ctx, "old")
+	ast.Nil(err)
+	ast.Equal("value", value)
+
+	version, err := bridge.IncrementNamespaceVersion(ctx)
+	ast.Nil(err)
+	ast.Equal(int64(1), version)
+
+	_, err = bridge.Get(ctx, "old")
+	ast.Equal(errors.ErrEmptyCache, err)
+
+	fetches := 0
+	fetcher := func() (interface{}, time.Duration, error) {
+		fetches++
+		return "new", time.Minute, nil
+	}
+	for i := 0; i < 2; i++ {
+		value, err := bridge.FetchWithString(ctx, "name", fetcher)
+		ast.Nil(err)
+		ast.Equal("new", value)
+	}
+	ast.Equal(1, fetches)
+
+	// 底层的key带有版本号
+	value, err = cache.Get(ctx, "__v1__:name")
+	ast.Nil(err)
+	ast.NotNil(value)
+
+	_, err = bridge.IncrementNamespaceVersion(ctx)
+	ast.Nil(err)
+	_, err = bridge.FetchWithString(ctx, "name", fetcher)
+	ast.Nil(err)
+	ast.Equal(2, fetches)
+}
+
+func TestBridgeNamespaceVersion_KeyCollision(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	m := miniredis.NewMiniRedis()
+	ast.Nil(m.Start())
+	defer m.Close()
+	cli := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	defer cli.Close()
+
+	bridge := MustNewBridge(WithCache(redisCache.NewRedisCache(cli)), WithNamespaceVersion(time.Minute))
+	bridge.SetNamespace("collision")
+
+	// 版本号为0时写入的key和增加版本号之后的key不会冲突
+	ast.Nil(bridge.Set(ctx, "v1:profile", "v1 profile", time.Minute))
+	_, err := bridge.IncrementNamespaceVersion(ctx)
+	ast.Nil(err)
+	_, err = bridge.Get(ctx, "profile")
+	ast.Equal(errors.ErrEmptyCache, err)
+}
+
+func TestBridgeNamespaceVersion_Refresh(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	redisCli := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+		DB:   0,
+	})
+	defer redisCli.Close()
+	redisCli.Del(ctx, "test_version:__gocache__:version")
+
+	newBridge := func() Bridge {
+		bridge := MustNewBridge(WithCache(redisCache.NewRedisCache(redisCli)), WithNamespaceVersion(100*time.Millisecond))
+		bridge.SetNamespace("test_version")
+		return bridge
+	}
+	b1, b2 := newBridge(), newBridge()
+
+	ast.Nil(b1.Set(ctx, "name", "v0", time.Minute))
+	value, err := b2.Get(ctx, "name")
+	ast.Nil(err)
+	ast.Equal([]byte("v0"), value)
+
+	version, err := b1.IncrementNamespaceVersion(ctx)
+	ast.Nil(err)
+	ast.Equal(int64(1), version)
+	_, err = b1.Get(ctx, "name")
+	ast.Equal(errors.ErrEmptyCache, err)
+
+	// b2在refreshInterval之内继续使用旧的版本号
+	value, err = b2.Get(ctx, "name")
+	ast.Nil(err)
+	ast.Equal([]byte("v0"), value)
+
+	time.Sleep(150 * time.Millisecond)
+	_, err = b2.Get(ctx, "name")
+	ast.Equal(errors.ErrEmptyCache, err)
+
+	ast.Nil(redisCli.Del(ctx, "test_version:name", "test_version:__gocache__:version").Err())
+}